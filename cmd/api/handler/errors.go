@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Codes of the errors returned by the API, besides the service.ErrCode* upstream codes
+const (
+	ErrCodeInvalidBody     = "invalid_body"
+	ErrCodeInvalidQuery    = "invalid_query"
+	ErrCodeNotFound        = "not_found"
+	ErrCodeValidation      = "validation_failed"
+	ErrCodeInvalidUpstream = "invalid_upstream_response"
+	ErrCodeUpstreamTimeout = "upstream_timeout"
+	ErrCodeCircuitOpen     = "circuit_open"
+	ErrCodeInternal        = "internal_error"
+)
+
+// respondError aborts the request with the standard error envelope
+func respondError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, domain.ErrorResponse{
+		Error: domain.APIError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+// respondServiceError translates an error returned by the quote service into its
+// HTTP status and error envelope
+func respondServiceError(c *gin.Context, err error) {
+	var validationErr *domain.ValidationError
+	var upstreamErr *service.UpstreamError
+	var decodeErr *service.ResponseDecodeError
+	switch {
+	case errors.As(err, &validationErr):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, domain.ErrorResponse{
+			Error: domain.APIError{
+				Code:    ErrCodeValidation,
+				Message: "the request has invalid fields",
+				Details: validationErr.Violations,
+			},
+		})
+	case errors.As(err, &upstreamErr):
+		c.AbortWithStatusJSON(upstreamErrorStatus(upstreamErr), domain.ErrorResponse{
+			Error: domain.APIError{
+				Code:           upstreamErr.Code,
+				Message:        upstreamErr.Message,
+				UpstreamStatus: upstreamErr.UpstreamStatus,
+			},
+		})
+	case errors.As(err, &decodeErr):
+		respondError(c, http.StatusBadGateway, ErrCodeInvalidUpstream, decodeErr.Error())
+	case errors.Is(err, service.ErrUpstreamTimeout):
+		respondError(c, http.StatusGatewayTimeout, ErrCodeUpstreamTimeout, err.Error())
+	case errors.Is(err, service.ErrCircuitOpen):
+		respondError(c, http.StatusServiceUnavailable, ErrCodeCircuitOpen, err.Error())
+	default:
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+	}
+}
+
+func upstreamErrorStatus(err *service.UpstreamError) int {
+	switch err.Code {
+	case service.ErrCodeInvalidCredentials, service.ErrCodeInvalidRequest:
+		return http.StatusBadRequest
+	case service.ErrCodeUnserviceableZipcode:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadGateway
+	}
+}
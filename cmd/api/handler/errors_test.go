@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondServiceError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedError  domain.APIError
+	}{
+		{
+			name:           "invalid credentials",
+			err:            &service.UpstreamError{Code: service.ErrCodeInvalidCredentials, Message: "Token inválido", UpstreamStatus: 401},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  domain.APIError{Code: service.ErrCodeInvalidCredentials, Message: "Token inválido", UpstreamStatus: 401},
+		},
+		{
+			name:           "unserviceable zipcode wrapped by the provider name",
+			err:            fmt.Errorf("frete_rapido: %w", &service.UpstreamError{Code: service.ErrCodeUnserviceableZipcode, Message: "CEP não atendido", UpstreamStatus: 422}),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  domain.APIError{Code: service.ErrCodeUnserviceableZipcode, Message: "CEP não atendido", UpstreamStatus: 422},
+		},
+		{
+			name:           "upstream outage",
+			err:            &service.UpstreamError{Code: service.ErrCodeUpstreamUnavailable, Message: "failed", UpstreamStatus: 500},
+			expectedStatus: http.StatusBadGateway,
+			expectedError:  domain.APIError{Code: service.ErrCodeUpstreamUnavailable, Message: "failed", UpstreamStatus: 500},
+		},
+		{
+			name:           "invalid upstream response",
+			err:            &service.ResponseDecodeError{Path: "dispatchers", Err: errors.New("missing required field")},
+			expectedStatus: http.StatusBadGateway,
+			expectedError:  domain.APIError{Code: ErrCodeInvalidUpstream, Message: "invalid Frete Rápido response at dispatchers: missing required field"},
+		},
+		{
+			name:           "timeout",
+			err:            service.ErrUpstreamTimeout,
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedError:  domain.APIError{Code: ErrCodeUpstreamTimeout, Message: service.ErrUpstreamTimeout.Error()},
+		},
+		{
+			name:           "circuit open",
+			err:            service.ErrCircuitOpen,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  domain.APIError{Code: ErrCodeCircuitOpen, Message: service.ErrCircuitOpen.Error()},
+		},
+		{
+			name:           "unknown error",
+			err:            errors.New("boom"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  domain.APIError{Code: ErrCodeInternal, Message: "boom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+
+			respondServiceError(c, tt.err)
+
+			var response domain.ErrorResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedError, response.Error)
+		})
+	}
+}
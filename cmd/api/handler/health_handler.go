@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/belmadge/freteRapido/infra/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+)
+
+// HealthHandler reports the health of the service and of its upstream circuit breakers
+type HealthHandler struct {
+	breakers []*service.CircuitBreaker
+}
+
+// NewHealthHandler creates a HealthHandler that reports the state of breakers
+func NewHealthHandler(breakers ...*service.CircuitBreaker) *HealthHandler {
+	return &HealthHandler{breakers: breakers}
+}
+
+// GetHealth handles the health check. The service is reported as degraded while any
+// circuit breaker is not closed.
+func (h *HealthHandler) GetHealth(c *gin.Context) {
+	status := HealthStatusOK
+	breakers := make(map[string]service.CircuitBreakerStatus, len(h.breakers))
+
+	for _, breaker := range h.breakers {
+		breakerStatus := breaker.Status()
+		if breakerStatus.State != service.CircuitClosed {
+			status = HealthStatusDegraded
+		}
+		breakers[breaker.Name()] = breakerStatus
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":           status,
+		"circuit_breakers": breakers,
+	})
+}
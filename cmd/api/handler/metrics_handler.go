@@ -1,38 +1,89 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/belmadge/freteRapido/domain"
-	"github.com/belmadge/freteRapido/infra/repository/db"
-	"github.com/belmadge/freteRapido/utils"
+	"github.com/belmadge/freteRapido/infra/repository"
 	"github.com/gin-gonic/gin"
 )
 
+// DefaultLastQuotes is the number of latest quotes the metrics are calculated over when
+// no window is given
 const DefaultLastQuotes = 10
 
-// GetMetricsHandler handles the retrieval of metrics based on the quotes stored in the database
-func GetMetricsHandler(c *gin.Context) {
-	lastQuotesParam := c.Query("last_quotes")
+// MetricsHandler serves the metrics calculated over the stored quotes
+type MetricsHandler struct {
+	repository repository.QuoteRepository
+}
 
-	lastQuotes, err := strconv.Atoi(lastQuotesParam)
-	if err != nil || lastQuotes <= 0 {
-		lastQuotes = DefaultLastQuotes
-	}
+// NewMetricsHandler creates a MetricsHandler that reads the quotes from repository
+func NewMetricsHandler(repository repository.QuoteRepository) *MetricsHandler {
+	return &MetricsHandler{repository: repository}
+}
 
-	var quotes []domain.Quote
-	result := db.DB.Preload("Carrier").Order("created_at desc").Limit(lastQuotes).Find(&quotes)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error fetching quotes"})
+// GetMetrics handles the retrieval of metrics based on the quotes stored in the database
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	filter, err := parseMetricsFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidQuery, err.Error())
 		return
 	}
 
-	metrics, err := utils.CalculateMetrics(quotes)
+	metrics, err := h.repository.Aggregate(c.Request.Context(), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to calculate metrics"})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "failed to calculate metrics")
 		return
 	}
 
 	c.JSON(http.StatusOK, metrics)
 }
+
+// parseMetricsFilter reads the filters of the metrics from the query. Without from, to
+// or last_quotes the metrics cover the DefaultLastQuotes latest quotes; with a date
+// range they cover every quote within it unless last_quotes is also given.
+func parseMetricsFilter(c *gin.Context) (domain.MetricsFilter, error) {
+	var filter domain.MetricsFilter
+	var err error
+
+	if filter.From, err = parseTimeQuery(c, "from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to", true); err != nil {
+		return filter, err
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, errors.New("invalid date range: from must be before to")
+	}
+
+	fallback := 0
+	if filter.From == nil && filter.To == nil {
+		fallback = DefaultLastQuotes
+	}
+	if filter.LastQuotes, err = parseIntQuery(c, "last_quotes", fallback); err != nil {
+		return filter, err
+	}
+
+	if filter.MinPrice, err = parseMoneyQuery(c, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = parseMoneyQuery(c, "max_price"); err != nil {
+		return filter, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("invalid price range: min_price must not be greater than max_price")
+	}
+
+	if filter.OriginZipcodePrefix, err = parseCEPPrefixQuery(c, "origin_zipcode"); err != nil {
+		return filter, err
+	}
+	if filter.DestinationZipcodePrefix, err = parseCEPPrefixQuery(c, "destination_zipcode"); err != nil {
+		return filter, err
+	}
+
+	filter.Carrier = c.Query("carrier")
+	filter.Service = c.Query("service")
+
+	return filter, nil
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository/memory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler_GetMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repository := memory.NewQuoteRepository()
+	for _, price := range []domain.Money{1000, 2000, 3000} {
+		quote := domain.Quote{Carrier: []domain.Carrier{{Name: "Correios", Price: price}}}
+		assert.NoError(t, repository.Save(context.Background(), &quote))
+	}
+
+	r := gin.New()
+	r.GET("/metrics", NewMetricsHandler(repository).GetMetrics)
+
+	recorder := serve(r, http.MethodGet, "/metrics?last_quotes=2", "")
+
+	var metrics domain.Metrics
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"total_price":50.00`)
+	assert.Contains(t, recorder.Body.String(), `"price_stats":{"min":20.00,"max":30.00,"median":25.00,"p90":29.00,"p95":29.50,"std_dev":5.00}`)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &metrics))
+	assert.Equal(t, domain.CurrencyBRL, metrics.Currency)
+	assert.False(t, metrics.Approximate)
+	priceStats := domain.PriceStats{Min: 2000, Max: 3000, Median: 2500, P90: 2900, P95: 2950, StdDev: 500}
+	assert.Equal(t, domain.CarrierMetrics{
+		Count: 2, TotalPrice: 5000, AveragePrice: 2500, PriceStats: priceStats,
+		Services: map[string]domain.ServiceMetrics{
+			"": {Count: 2, TotalPrice: 5000, AveragePrice: 2500, PriceStats: priceStats},
+		},
+	}, metrics.Carriers["Correios"])
+}
+
+func TestMetricsHandler_GetMetrics_NoQuotes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/metrics", NewMetricsHandler(memory.NewQuoteRepository()).GetMetrics)
+
+	recorder := serve(r, http.MethodGet, "/metrics", "")
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{
+		"window": {"from": null, "to": null, "last_quotes": 10, "quotes": 0},
+		"currency": "BRL",
+		"approximate": false,
+		"carriers": {},
+		"cheapest_quote": null,
+		"most_expensive_quote": null,
+		"fastest_quote": null,
+		"slowest_quote": null,
+		"cost_vs_speed": null
+	}`, recorder.Body.String())
+}
+
+func TestMetricsHandler_GetMetrics_Filters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repository := memory.NewQuoteRepository()
+	quotes := []domain.Quote{
+		{RecipientZipcode: "01001000", Carrier: []domain.Carrier{{Name: "Correios", Service: "SEDEX", Price: 3000}, {Name: "Jadlog", Price: 2000}}},
+		{RecipientZipcode: "29161376", Carrier: []domain.Carrier{{Name: "Correios", Service: "SEDEX", Price: 1500}}},
+		{RecipientZipcode: "01310100", Carrier: []domain.Carrier{{Name: "Correios", Service: "PAC", Price: 1000}}},
+	}
+	for i := range quotes {
+		quotes[i].CreatedAt = time.Date(2024, 5, 30+i, 12, 0, 0, 0, time.UTC)
+		assert.NoError(t, repository.Save(context.Background(), &quotes[i]))
+	}
+
+	r := gin.New()
+	r.GET("/metrics", NewMetricsHandler(repository).GetMetrics)
+
+	recorder := serve(r, http.MethodGet, "/metrics?from=2024-05-01&to=2024-05-31&carrier=Correios&destination_zipcode=01", "")
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var metrics domain.Metrics
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &metrics))
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, domain.MetricsWindow{From: &from, To: &to, Quotes: 1}, metrics.Window)
+	priceStats := domain.PriceStats{Min: 3000, Max: 3000, Median: 3000, P90: 3000, P95: 3000}
+	assert.Equal(t, map[string]domain.CarrierMetrics{
+		"Correios": {
+			Count: 1, TotalPrice: 3000, AveragePrice: 3000, PriceStats: priceStats,
+			Services: map[string]domain.ServiceMetrics{
+				"SEDEX": {Count: 1, TotalPrice: 3000, AveragePrice: 3000, PriceStats: priceStats},
+			},
+		},
+	}, metrics.Carriers)
+}
+
+func TestMetricsHandler_GetMetrics_InvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name            string
+		query           string
+		expectedMessage string
+	}{
+		{
+			name:            "invalid last_quotes",
+			query:           "last_quotes=0",
+			expectedMessage: "invalid last_quotes: expected a positive integer",
+		},
+		{
+			name:            "invalid from",
+			query:           "from=yesterday",
+			expectedMessage: "invalid from: expected a date (YYYY-MM-DD) or an RFC 3339 timestamp",
+		},
+		{
+			name:            "inverted date range",
+			query:           "from=2024-06-02&to=2024-06-01",
+			expectedMessage: "invalid date range: from must be before to",
+		},
+		{
+			name:            "inverted price range",
+			query:           "min_price=20&max_price=10",
+			expectedMessage: "invalid price range: min_price must not be greater than max_price",
+		},
+		{
+			name:            "invalid origin zipcode",
+			query:           "origin_zipcode=01A",
+			expectedMessage: "invalid origin_zipcode: expected the first 1 to 8 digits of a CEP",
+		},
+		{
+			name:            "destination zipcode too long",
+			query:           "destination_zipcode=013101000",
+			expectedMessage: "invalid destination_zipcode: expected the first 1 to 8 digits of a CEP",
+		},
+	}
+
+	r := gin.New()
+	r.GET("/metrics", NewMetricsHandler(memory.NewQuoteRepository()).GetMetrics)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(r, http.MethodGet, "/metrics?"+tt.query, "")
+
+			var response domain.ErrorResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Equal(t, ErrCodeInvalidQuery, response.Error.Code)
+			assert.Equal(t, tt.expectedMessage, response.Error.Message)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// parseTimeQuery reads a query parameter given either as an RFC 3339 timestamp or as a
+// date. Dates are taken as the start of the day, or as the start of the next day when
+// endOfDay is set, so that a date used as the end of a range includes the whole day.
+// Timestamps are converted to UTC, the time zone the quotes are stored in.
+func parseTimeQuery(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		parsed = parsed.UTC()
+		return &parsed, nil
+	}
+
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected a date (YYYY-MM-DD) or an RFC 3339 timestamp", key)
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+
+	return &parsed, nil
+}
+
+// parseMoneyQuery reads an optional non-negative amount of reais from the query
+func parseMoneyQuery(c *gin.Context, key string) (*domain.Money, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	amount, err := domain.ParseMoney(value)
+	if err != nil || amount < 0 {
+		return nil, fmt.Errorf("invalid %s: expected a non-negative amount", key)
+	}
+
+	return &amount, nil
+}
+
+// parseCEPPrefixQuery reads an optional prefix of a CEP, from 1 to 8 digits, from the
+// query. A hyphen, as in "01310-", is ignored.
+func parseCEPPrefixQuery(c *gin.Context, key string) (string, error) {
+	prefix := strings.ReplaceAll(c.Query(key), "-", "")
+	if prefix != "" && !domain.ValidCEPPrefix(prefix) {
+		return "", fmt.Errorf("invalid %s: expected the first 1 to 8 digits of a CEP", key)
+	}
+
+	return prefix, nil
+}
+
+// parseIntQuery reads an optional positive integer from the query, returning fallback
+// when it is not set
+func parseIntQuery(c *gin.Context, key string, fallback int) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid %s: expected a positive integer", key)
+	}
+
+	return number, nil
+}
@@ -1,43 +1,158 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/belmadge/freteRapido/domain"
-	"github.com/belmadge/freteRapido/infra/repository/db"
-	"github.com/belmadge/freteRapido/infra/service"
-	"github.com/belmadge/freteRapido/utils"
+	"github.com/belmadge/freteRapido/infra/repository"
 	"github.com/gin-gonic/gin"
 )
 
-// CreateQuoteHandler handles the creation of a new quote
-func CreateQuoteHandler(c *gin.Context) {
+// QuoteService is the behaviour QuoteHandler needs to create quotes
+type QuoteService interface {
+	CreateQuote(ctx context.Context, input domain.QuoteRequest) (*domain.QuoteResponse, error)
+}
+
+// QuoteHandler serves the quote endpoints
+type QuoteHandler struct {
+	service    QuoteService
+	repository repository.QuoteRepository
+}
+
+// NewQuoteHandler creates a QuoteHandler that creates quotes through service and
+// stores them in repository
+func NewQuoteHandler(service QuoteService, repository repository.QuoteRepository) *QuoteHandler {
+	return &QuoteHandler{
+		service:    service,
+		repository: repository,
+	}
+}
+
+// CreateQuote handles the creation of a new quote
+func (h *QuoteHandler) CreateQuote(c *gin.Context) {
 	var input domain.QuoteRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
 		return
 	}
 
-	if err := utils.ValidateQuoteInput(input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	quoteResponse, err := h.service.CreateQuote(c.Request.Context(), input)
+	if err != nil {
+		respondServiceError(c, err)
+		return
+	}
+
+	quote := domain.NewQuote(input, quoteResponse.Carrier)
+
+	if err := h.repository.Save(c.Request.Context(), &quote); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "error saving quote to database")
 		return
 	}
 
-	quoteResponse, err := service.CreateQuote(input)
+	c.JSON(http.StatusCreated, quoteResponse)
+}
+
+// GetQuote handles the retrieval of a stored quote with its carriers
+func (h *QuoteHandler) GetQuote(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidQuery, "invalid quote id")
+		return
+	}
+
+	quote, err := h.repository.GetByID(c.Request.Context(), uint(id))
+	if errors.Is(err, domain.ErrQuoteNotFound) {
+		respondError(c, http.StatusNotFound, ErrCodeNotFound, err.Error())
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "error fetching quote")
 		return
 	}
 
-	quote := domain.Quote{
-		Carrier: quoteResponse.Carrier,
+	c.JSON(http.StatusOK, quote.Details())
+}
+
+// ListQuotes handles the paginated listing of the stored quotes
+func (h *QuoteHandler) ListQuotes(c *gin.Context) {
+	filter, err := parseQuoteFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidQuery, err.Error())
+		return
 	}
 
-	result := db.DB.Create(&quote)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error saving quote to database"})
+	quotes, nextCursor, err := h.repository.List(c.Request.Context(), filter)
+	if errors.Is(err, domain.ErrInvalidCursor) {
+		respondError(c, http.StatusBadRequest, ErrCodeInvalidQuery, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCodeInternal, "error fetching quotes")
 		return
 	}
 
-	c.JSON(http.StatusCreated, quoteResponse)
+	page := domain.QuotePage{
+		Quotes:     make([]domain.QuoteDetails, 0, len(quotes)),
+		NextCursor: nextCursor,
+	}
+	for _, quote := range quotes {
+		page.Quotes = append(page.Quotes, quote.Details())
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func parseQuoteFilter(c *gin.Context) (domain.QuoteFilter, error) {
+	var filter domain.QuoteFilter
+	var err error
+
+	if filter.Sort, err = domain.ParseQuoteSort(c.Query("sort")); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = parseIntQuery(c, "limit", domain.DefaultQuotePageSize); err != nil {
+		return filter, err
+	}
+	if filter.Limit > domain.MaxQuotePageSize {
+		return filter, fmt.Errorf("invalid limit: must be at most %d", domain.MaxQuotePageSize)
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if filter.After, err = domain.DecodeQuoteCursor(cursor, filter.Sort); err != nil {
+			return filter, err
+		}
+	}
+
+	if filter.From, err = parseTimeQuery(c, "from", false); err != nil {
+		return filter, err
+	}
+	if filter.To, err = parseTimeQuery(c, "to", true); err != nil {
+		return filter, err
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, errors.New("invalid date range: from must be before to")
+	}
+
+	if filter.MinPrice, err = parseMoneyQuery(c, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = parseMoneyQuery(c, "max_price"); err != nil {
+		return filter, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("invalid price range: min_price must not be greater than max_price")
+	}
+
+	if zipcode := c.Query("recipient_zipcode"); zipcode != "" {
+		if filter.RecipientZipcode, err = domain.ParseCEP(zipcode); err != nil {
+			return filter, errors.New("invalid recipient_zipcode: expected a CEP with 8 digits")
+		}
+	}
+
+	filter.Carrier = c.Query("carrier")
+	filter.Service = c.Query("service")
+
+	return filter, nil
 }
@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository/memory"
+	"github.com/belmadge/freteRapido/infra/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubQuoteService struct {
+	response *domain.QuoteResponse
+	err      error
+	input    domain.QuoteRequest
+}
+
+func (s *stubQuoteService) CreateQuote(ctx context.Context, input domain.QuoteRequest) (*domain.QuoteResponse, error) {
+	s.input = input
+	return s.response, s.err
+}
+
+const validQuoteBody = `{
+	"shipper": {"registered_number": "25438296000158", "token": "secret", "platform_code": "5AKVkHqCn"},
+	"recipient": {"type": 0, "country": "BRA", "zipcode": 29161376},
+	"dispatchers": [{
+		"registered_number": "25438296000158",
+		"zipcode": 29161376,
+		"volumes": [{"category": "7", "amount": 1, "unitary_weight": 5, "unitary_price": 349, "height": 0.2, "width": 0.2, "length": 0.2}]
+	}],
+	"simulation_type": [0]
+}`
+
+func newQuoteRouter(service QuoteService, repository *memory.QuoteRepository) *gin.Engine {
+	quoteHandler := NewQuoteHandler(service, repository)
+
+	r := gin.New()
+	r.POST("/quote", quoteHandler.CreateQuote)
+	r.GET("/quote/:id", quoteHandler.GetQuote)
+	r.GET("/quotes", quoteHandler.ListQuotes)
+	return r
+}
+
+func serve(r *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return recorder
+}
+
+func TestQuoteHandler_CreateQuote(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repository := memory.NewQuoteRepository()
+	service := &stubQuoteService{
+		response: &domain.QuoteResponse{
+			Carrier: []domain.Carrier{{Name: "Correios", Service: "SEDEX", Deadline: 1, Price: 2099}},
+		},
+	}
+
+	recorder := serve(newQuoteRouter(service, repository), http.MethodPost, "/quote", validQuoteBody)
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.JSONEq(t, `{"carrier": [{
+		"name": "Correios", "service": "SEDEX", "deadline": 1, "price": 20.99, "currency": "", "provider": "",
+		"carrier_registered_number": "", "carrier_reference": 0, "dispatcher_id": "", "offer_id": 0,
+		"cost_price": 0.00, "cubic_weight": 0, "expires_at": null, "estimated_delivery_date": null
+	}]}`, recorder.Body.String())
+
+	stored, err := repository.GetByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.RegisteredNumber("25438296000158"), stored.ShipperRegisteredNumber)
+	assert.Equal(t, "", stored.Request().Shipper.Token)
+	assert.Len(t, stored.Carrier, 1)
+}
+
+func TestQuoteHandler_CreateQuote_Error(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		body           string
+		service        *stubQuoteService
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "invalid body",
+			body:           `{"shipper": "invalid"}`,
+			service:        &stubQuoteService{},
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   ErrCodeInvalidBody,
+		},
+		{
+			name:           "invalid quote request",
+			body:           validQuoteBody,
+			service:        &stubQuoteService{err: &domain.ValidationError{Violations: []domain.FieldViolation{{Field: "/shipper/token", Rule: domain.RuleRequired, Message: "is required"}}}},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCode:   ErrCodeValidation,
+		},
+		{
+			name:           "service error",
+			body:           validQuoteBody,
+			service:        &stubQuoteService{err: errors.New("boom")},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   ErrCodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := memory.NewQuoteRepository()
+
+			recorder := serve(newQuoteRouter(tt.service, repository), http.MethodPost, "/quote", tt.body)
+
+			var response domain.ErrorResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedCode, response.Error.Code)
+
+			_, err := repository.GetByID(context.Background(), 1)
+			assert.ErrorIs(t, err, domain.ErrQuoteNotFound, "failed quotes are not stored")
+		})
+	}
+}
+
+func TestQuoteHandler_CreateQuote_ValidationDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body := strings.Replace(validQuoteBody, `"unitary_weight": 5`, `"unitary_weight": 0`, 1)
+	body = strings.Replace(body, `"token": "secret"`, `"token": ""`, 1)
+	body = strings.Replace(body, `"height": 0.2`, `"height": 20`, 1)
+	quoteService := service.NewQuoteService(time.Second, domain.DefaultQuoteLimits)
+
+	recorder := serve(newQuoteRouter(quoteService, memory.NewQuoteRepository()), http.MethodPost, "/quote", body)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.JSONEq(t, `{"error": {
+		"code": "validation_failed",
+		"message": "the request has invalid fields",
+		"details": [
+			{"field": "/shipper/token", "rule": "required", "message": "is required"},
+			{"field": "/dispatchers/0/volumes/0/height", "rule": "max", "message": "must be at most 3 m (dimensions are in meters)"},
+			{"field": "/dispatchers/0/volumes/0/unitary_weight", "rule": "positive", "message": "must be greater than zero"}
+		]
+	}}`, recorder.Body.String())
+}
+
+func TestQuoteHandler_CreateQuote_NormalizesRegisteredNumbers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	service := &stubQuoteService{response: &domain.QuoteResponse{Carrier: []domain.Carrier{}}}
+	body := strings.Replace(validQuoteBody, `"registered_number": "25438296000158"`, `"registered_number": "25.438.296/0001-58"`, 1)
+	body = strings.Replace(body, `"registered_number": "25438296000158"`, `"registered_number": "12.abc.345/01de-35"`, 1)
+
+	recorder := serve(newQuoteRouter(service, memory.NewQuoteRepository()), http.MethodPost, "/quote", body)
+
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	assert.Equal(t, domain.RegisteredNumber("25438296000158"), service.input.Shipper.RegisteredNumber)
+	assert.Equal(t, domain.RegisteredNumber("12ABC34501DE35"), service.input.Dispatchers[0].RegisteredNumber)
+}
+
+func TestQuoteHandler_GetQuote(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repository := memory.NewQuoteRepository()
+	quote := domain.Quote{RecipientCountry: "BRA", RecipientZipcode: "29161376", Carrier: []domain.Carrier{{Name: "Correios", Price: 2099}}}
+	assert.NoError(t, repository.Save(context.Background(), &quote))
+	r := newQuoteRouter(&stubQuoteService{}, repository)
+
+	recorder := serve(r, http.MethodGet, "/quote/1", "")
+	var details domain.QuoteDetails
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &details))
+	assert.Equal(t, uint(1), details.ID)
+	assert.Equal(t, domain.CEP("29161376"), details.Request.Recipient.Zipcode)
+	assert.Equal(t, "Correios", details.Carrier[0].Name)
+
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/quote/2", "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/quote/abc", "").Code)
+}
+
+func TestQuoteHandler_ListQuotes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repository := memory.NewQuoteRepository()
+	for i := 0; i < 3; i++ {
+		quote := domain.Quote{CreatedAt: time.Date(2024, 6, 1+i, 0, 0, 0, 0, time.UTC)}
+		assert.NoError(t, repository.Save(context.Background(), &quote))
+	}
+	r := newQuoteRouter(&stubQuoteService{}, repository)
+
+	recorder := serve(r, http.MethodGet, "/quotes?limit=2", "")
+	var page domain.QuotePage
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+	assert.Len(t, page.Quotes, 2)
+	assert.Equal(t, uint(3), page.Quotes[0].ID)
+	assert.NotEmpty(t, page.NextCursor)
+
+	recorder = serve(r, http.MethodGet, "/quotes?limit=2&cursor="+page.NextCursor, "")
+	page = domain.QuotePage{}
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &page))
+	assert.Len(t, page.Quotes, 1)
+	assert.Equal(t, uint(1), page.Quotes[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	assert.Equal(t, http.StatusBadRequest, serve(r, http.MethodGet, "/quotes?sort=price", "").Code)
+}
+
+func newQueryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/quotes?"+query, nil)
+	return c
+}
+
+func TestParseQuoteFilter_Success(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	minPrice, maxPrice := domain.Money(1000), domain.Money(5050)
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected domain.QuoteFilter
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			expected: domain.QuoteFilter{
+				Sort:  domain.DefaultQuoteSort,
+				Limit: domain.DefaultQuotePageSize,
+			},
+		},
+		{
+			name:  "all filters",
+			query: "from=2024-06-01&to=2024-06-30&carrier=Correios&service=SEDEX&min_price=10&max_price=50.5&recipient_zipcode=29161376&sort=total_weight&limit=5",
+			expected: domain.QuoteFilter{
+				From:             &from,
+				To:               &to,
+				Carrier:          "Correios",
+				Service:          "SEDEX",
+				MinPrice:         &minPrice,
+				MaxPrice:         &maxPrice,
+				RecipientZipcode: "29161376",
+				Sort:             domain.QuoteSort{Field: domain.SortByTotalWeight},
+				Limit:            5,
+			},
+		},
+		{
+			name:  "timestamps with an offset",
+			query: "from=2024-05-31T21:00:00-03:00&to=2024-07-01T09:00:00%2B09:00",
+			expected: domain.QuoteFilter{
+				From:  &from,
+				To:    &to,
+				Sort:  domain.DefaultQuoteSort,
+				Limit: domain.DefaultQuotePageSize,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := parseQuoteFilter(newQueryContext(tt.query))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, filter)
+		})
+	}
+}
+
+func TestParseQuoteFilter_Error(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "invalid sort", query: "sort=price", wantErr: `invalid sort "price"`},
+		{name: "invalid limit", query: "limit=0", wantErr: "invalid limit: expected a positive integer"},
+		{name: "limit too large", query: "limit=101", wantErr: "invalid limit: must be at most 100"},
+		{name: "invalid cursor", query: "cursor=abc", wantErr: "invalid cursor"},
+		{name: "invalid from", query: "from=yesterday", wantErr: "invalid from: expected a date (YYYY-MM-DD) or an RFC 3339 timestamp"},
+		{name: "inverted date range", query: "from=2024-07-01&to=2024-06-01", wantErr: "invalid date range: from must be before to"},
+		{name: "invalid min price", query: "min_price=-1", wantErr: "invalid min_price: expected a non-negative amount"},
+		{name: "inverted price range", query: "min_price=20&max_price=10", wantErr: "invalid price range: min_price must not be greater than max_price"},
+		{name: "invalid zipcode", query: "recipient_zipcode=abc", wantErr: "invalid recipient_zipcode: expected a CEP with 8 digits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseQuoteFilter(newQueryContext(tt.query))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -1,21 +1,67 @@
 package main
 
 import (
+	"context"
+	"os"
+
 	"github.com/belmadge/freteRapido/cmd/api/handler"
 	"github.com/belmadge/freteRapido/config"
 	"github.com/belmadge/freteRapido/infra/repository/db"
+	"github.com/belmadge/freteRapido/infra/service"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	config.LoadConfig()
-	db.InitDB()
+	if err := db.InitDB(); err != nil {
+		logrus.Fatalf("failed to initialize database: %s", err.Error())
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	if err := db.NewMigrator(db.DB, db.Migrations).CheckSchema(context.Background()); err != nil {
+		logrus.Fatalf("refusing to start: %s, run `main migrate up` first", err.Error())
+	}
+
+	httpClient := service.NewHTTPClient(
+		config.Config.UpstreamConnectTimeout,
+		config.Config.UpstreamResponseHeaderTimeout,
+		config.Config.UpstreamTimeout,
+	)
+	retryClient := service.NewRetryClient(
+		httpClient,
+		config.Config.UpstreamMaxRetries,
+		config.Config.UpstreamRetryBaseDelay,
+		config.Config.UpstreamRetryMaxDelay,
+	)
+	freteRapidoBreaker := service.NewCircuitBreaker(
+		service.FreteRapidoProviderName,
+		config.Config.CircuitBreakerFailureThreshold,
+		config.Config.CircuitBreakerOpenTimeout,
+	)
+	provider := service.NewFreteRapidoProvider(
+		service.NewCircuitBreakerClient(retryClient, freteRapidoBreaker),
+		service.FreteRapidoSimulateURL,
+	)
+
+	quoteRepository := db.NewQuoteRepository(db.DB)
+	go quoteRepository.RunRollupCompaction(context.Background(), config.Config.RollupCompactionInterval)
+
+	quoteHandler := handler.NewQuoteHandler(service.NewQuoteService(config.Config.UpstreamTotalTimeout, config.Config.QuoteLimits, provider), quoteRepository)
+	metricsHandler := handler.NewMetricsHandler(quoteRepository)
+	healthHandler := handler.NewHealthHandler(freteRapidoBreaker)
 
 	r := gin.Default()
 
-	r.POST("/quote", handler.CreateQuoteHandler)
-	r.GET("/metrics", handler.GetMetricsHandler)
+	r.POST("/quote", quoteHandler.CreateQuote)
+	r.GET("/quote/:id", quoteHandler.GetQuote)
+	r.GET("/quotes", quoteHandler.ListQuotes)
+	r.GET("/metrics", metricsHandler.GetMetrics)
+	r.GET("/health", healthHandler.GetHealth)
 
 	if err := r.Run(":8080"); err != nil {
 		logrus.Fatalf("failed to start server: %s", err.Error())
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/belmadge/freteRapido/infra/repository/db"
+	"github.com/sirupsen/logrus"
+)
+
+const migrateUsage = "usage: main migrate up | down [steps] | status"
+
+// runMigrate runs the migrate subcommand with the arguments that follow it
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		logrus.Fatal(migrateUsage)
+	}
+
+	ctx := context.Background()
+	migrator := db.NewMigrator(db.DB, db.Migrations)
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			logrus.Infof("applied migration %d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			logrus.Fatalf("failed to apply migrations: %s", err.Error())
+		}
+		if len(applied) == 0 {
+			logrus.Info("database schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				logrus.Fatal(migrateUsage)
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			logrus.Infof("reverted migration %d_%s", migration.Version, migration.Name)
+		}
+		if err != nil {
+			logrus.Fatalf("failed to revert migrations: %s", err.Error())
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logrus.Fatalf("failed to read migration status: %s", err.Error())
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		logrus.Fatal(migrateUsage)
+	}
+}
@@ -2,17 +2,73 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/belmadge/freteRapido/domain"
 	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
 )
 
+// Database drivers supported by DB_DRIVER
+const (
+	DBDriverMySQL  = "mysql"
+	DBDriverSQLite = "sqlite"
+	DBDriverMemory = "memory"
+)
+
+const (
+	DefaultDBDriver = DBDriverMySQL
+	DefaultDBPath   = "frete_rapido.db"
+
+	DefaultDBConnectTimeout  = time.Minute
+	DefaultDBMaxOpenConns    = 25
+	DefaultDBMaxIdleConns    = 25
+	DefaultDBConnMaxLifetime = 5 * time.Minute
+
+	DefaultUpstreamConnectTimeout        = 5 * time.Second
+	DefaultUpstreamResponseHeaderTimeout = 10 * time.Second
+	DefaultUpstreamTimeout               = 15 * time.Second
+
+	DefaultUpstreamMaxRetries     = 2
+	DefaultUpstreamRetryBaseDelay = 200 * time.Millisecond
+	DefaultUpstreamRetryMaxDelay  = 2 * time.Second
+
+	DefaultCircuitBreakerFailureThreshold = 5
+	DefaultCircuitBreakerOpenTimeout      = 30 * time.Second
+
+	DefaultRollupCompactionInterval = time.Hour
+)
+
 var Config struct {
+	DBDriver   string
+	DBPath     string
 	DBUser     string
 	DBPassword string
 	DBName     string
 	DBHost     string
 	DBPort     string
+
+	DBConnectTimeout  time.Duration
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	UpstreamConnectTimeout        time.Duration
+	UpstreamResponseHeaderTimeout time.Duration
+	UpstreamTimeout               time.Duration
+	UpstreamTotalTimeout          time.Duration
+
+	UpstreamMaxRetries     int
+	UpstreamRetryBaseDelay time.Duration
+	UpstreamRetryMaxDelay  time.Duration
+
+	CircuitBreakerFailureThreshold int
+	CircuitBreakerOpenTimeout      time.Duration
+
+	RollupCompactionInterval time.Duration
+
+	QuoteLimits domain.QuoteLimits
 }
 
 func LoadConfig() {
@@ -20,9 +76,126 @@ func LoadConfig() {
 		logrus.Fatalf("error loading .env file: %s", err.Error())
 	}
 
+	Config.DBDriver = getString("DB_DRIVER", DefaultDBDriver)
+	switch Config.DBDriver {
+	case DBDriverMySQL, DBDriverSQLite, DBDriverMemory:
+	default:
+		logrus.Fatalf("invalid DB_DRIVER %q: expected %s, %s or %s", Config.DBDriver, DBDriverMySQL, DBDriverSQLite, DBDriverMemory)
+	}
+	Config.DBPath = getString("DB_PATH", DefaultDBPath)
 	Config.DBUser = os.Getenv("DB_USER")
 	Config.DBPassword = os.Getenv("DB_PASSWORD")
 	Config.DBName = os.Getenv("DB_NAME")
 	Config.DBHost = os.Getenv("DB_HOST")
 	Config.DBPort = os.Getenv("DB_PORT")
+
+	Config.DBConnectTimeout = getDuration("DB_CONNECT_TIMEOUT", DefaultDBConnectTimeout)
+	Config.DBMaxOpenConns = getInt("DB_MAX_OPEN_CONNS", DefaultDBMaxOpenConns)
+	Config.DBMaxIdleConns = getInt("DB_MAX_IDLE_CONNS", DefaultDBMaxIdleConns)
+	Config.DBConnMaxLifetime = getDuration("DB_CONN_MAX_LIFETIME", DefaultDBConnMaxLifetime)
+
+	Config.UpstreamConnectTimeout = getDuration("UPSTREAM_CONNECT_TIMEOUT", DefaultUpstreamConnectTimeout)
+	Config.UpstreamResponseHeaderTimeout = getDuration("UPSTREAM_RESPONSE_HEADER_TIMEOUT", DefaultUpstreamResponseHeaderTimeout)
+	Config.UpstreamTimeout = getDuration("UPSTREAM_TIMEOUT", DefaultUpstreamTimeout)
+
+	Config.UpstreamMaxRetries = getInt("UPSTREAM_MAX_RETRIES", DefaultUpstreamMaxRetries)
+	Config.UpstreamRetryBaseDelay = getDuration("UPSTREAM_RETRY_BASE_DELAY", DefaultUpstreamRetryBaseDelay)
+	Config.UpstreamRetryMaxDelay = getDuration("UPSTREAM_RETRY_MAX_DELAY", DefaultUpstreamRetryMaxDelay)
+
+	// By default every attempt may run until its own timeout and still be retried
+	retries := time.Duration(Config.UpstreamMaxRetries)
+	Config.UpstreamTotalTimeout = getDuration(
+		"UPSTREAM_TOTAL_TIMEOUT",
+		(retries+1)*Config.UpstreamTimeout+retries*Config.UpstreamRetryMaxDelay,
+	)
+	if Config.UpstreamTotalTimeout <= Config.UpstreamTimeout && Config.UpstreamMaxRetries > 0 {
+		logrus.Warnf("UPSTREAM_TOTAL_TIMEOUT %s does not exceed UPSTREAM_TIMEOUT %s: attempts that time out are never retried",
+			Config.UpstreamTotalTimeout, Config.UpstreamTimeout)
+	}
+
+	Config.CircuitBreakerFailureThreshold = getInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", DefaultCircuitBreakerFailureThreshold)
+	Config.CircuitBreakerOpenTimeout = getDuration("CIRCUIT_BREAKER_OPEN_TIMEOUT", DefaultCircuitBreakerOpenTimeout)
+
+	Config.RollupCompactionInterval = getDuration("ROLLUP_COMPACTION_INTERVAL", DefaultRollupCompactionInterval)
+
+	Config.QuoteLimits = domain.QuoteLimits{
+		MaxDimension:      getFloat("VOLUME_MAX_DIMENSION", domain.DefaultQuoteLimits.MaxDimension),
+		MaxVolumeWeight:   getFloat("VOLUME_MAX_WEIGHT", domain.DefaultQuoteLimits.MaxVolumeWeight),
+		MaxVolumeValue:    getMoney("VOLUME_MAX_VALUE", domain.DefaultQuoteLimits.MaxVolumeValue),
+		MaxShipmentWeight: getFloat("SHIPMENT_MAX_WEIGHT", domain.DefaultQuoteLimits.MaxShipmentWeight),
+		MaxShipmentValue:  getMoney("SHIPMENT_MAX_VALUE", domain.DefaultQuoteLimits.MaxShipmentValue),
+	}
+}
+
+// getString reads a variable from the environment, falling back to the default when
+// it is not set
+func getString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDuration reads a duration such as "5s" or "1m30s" from the environment,
+// falling back to the default when the variable is not set
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		logrus.Fatalf("invalid duration for %s: %q", key, value)
+	}
+
+	return duration
+}
+
+// getInt reads a non-negative integer from the environment, falling back to the
+// default when the variable is not set
+func getInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		logrus.Fatalf("invalid integer for %s: %q", key, value)
+	}
+
+	return number
+}
+
+// getFloat reads a non-negative number such as "2.5" from the environment, falling back
+// to the default when the variable is not set
+func getFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		logrus.Fatalf("invalid number for %s: %q", key, value)
+	}
+
+	return number
+}
+
+// getMoney reads a non-negative amount in reais such as "1500.00" from the environment,
+// falling back to the default when the variable is not set
+func getMoney(key string, fallback domain.Money) domain.Money {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	amount, err := domain.ParseMoney(value)
+	if err != nil || amount < 0 {
+		logrus.Fatalf("invalid amount for %s: %q", key, value)
+	}
+
+	return amount
 }
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCEP = errors.New("invalid CEP: expected 8 digits")
+
+// CEP is a Brazilian zipcode, kept as its 8 digits so that leading zeros such as the one
+// of 01310-100 are not lost
+type CEP string
+
+// cepFormatting are the separators clients write CEPs with, as in 01.310-100
+var cepFormatting = strings.NewReplacer("-", "", ".", "", " ", "")
+
+// ParseCEP parses a CEP written with or without separators, such as "01310-100"
+func ParseCEP(value string) (CEP, error) {
+	cep := CEP(cepFormatting.Replace(value))
+	if !cep.Valid() {
+		return "", fmt.Errorf("%w, got %q", ErrInvalidCEP, value)
+	}
+	return cep, nil
+}
+
+// Valid reports whether the CEP has exactly 8 digits
+func (c CEP) Valid() bool {
+	if len(c) != 8 {
+		return false
+	}
+	for _, r := range c {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidCEPPrefix reports whether prefix is made of the first 1 to 8 digits of a CEP,
+// such as "01" for the city of São Paulo
+func ValidCEPPrefix(prefix string) bool {
+	if len(prefix) == 0 || len(prefix) > 8 {
+		return false
+	}
+	for _, r := range prefix {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Int returns the CEP as a number, for APIs that take zipcodes as integers
+func (c CEP) Int() int {
+	number, _ := strconv.Atoi(string(c))
+	return number
+}
+
+// UnmarshalJSON accepts the CEP as a string, with or without separators, or as a number,
+// whose missing leading zeros are restored. Values that are not a CEP are kept as given,
+// without separators, so that validation can report them.
+func (c *CEP) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	switch {
+	case len(data) > 0 && data[0] == '"':
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*c = CEP(cepFormatting.Replace(value))
+	case len(data) > 0 && (data[0] == '-' || (data[0] >= '0' && data[0] <= '9')):
+		if number, err := strconv.ParseUint(string(data), 10, 64); err == nil && number < 1e8 {
+			*c = CEP(fmt.Sprintf("%08d", number))
+		} else {
+			*c = CEP(data)
+		}
+	default:
+		return &json.UnmarshalTypeError{Value: jsonValueKind(data), Type: reflect.TypeOf(c).Elem()}
+	}
+
+	return nil
+}
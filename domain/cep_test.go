@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCEP(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected CEP
+		wantErr  bool
+	}{
+		{value: "01310-100", expected: "01310100"},
+		{value: "01310100", expected: "01310100"},
+		{value: "01.310-100", expected: "01310100"},
+		{value: "1310-100", wantErr: true},
+		{value: "013101000", wantErr: true},
+		{value: "0131O100", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cep, err := ParseCEP(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidCEP)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, cep)
+		})
+	}
+}
+
+func TestCEP_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected CEP
+		valid    bool
+	}{
+		{name: "formatted string", body: `"01310-100"`, expected: "01310100", valid: true},
+		{name: "digits", body: `"01310100"`, expected: "01310100", valid: true},
+		{name: "number without its leading zero", body: `1310100`, expected: "01310100", valid: true},
+		{name: "number", body: `29161376`, expected: "29161376", valid: true},
+		{name: "short string", body: `"1310-100"`, expected: "1310100"},
+		{name: "long number", body: `291613760`, expected: "291613760"},
+		{name: "negative number", body: `-1310100`, expected: "-1310100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recipient Recipient
+			assert.NoError(t, json.Unmarshal([]byte(`{"zipcode": `+tt.body+`}`), &recipient))
+			assert.Equal(t, tt.expected, recipient.Zipcode)
+			assert.Equal(t, tt.valid, recipient.Zipcode.Valid())
+		})
+	}
+
+	var recipient Recipient
+	var typeErr *json.UnmarshalTypeError
+	assert.ErrorAs(t, json.Unmarshal([]byte(`{"zipcode": true}`), &recipient), &typeErr)
+}
+
+func TestCEP_JSON(t *testing.T) {
+	data, err := json.Marshal(Recipient{Zipcode: "01310100"})
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"zipcode":"01310100"`)
+	assert.Equal(t, 1310100, CEP("01310100").Int())
+}
+
+func TestValidCEPPrefix(t *testing.T) {
+	for prefix, expected := range map[string]bool{
+		"0":         true,
+		"01":        true,
+		"01310100":  true,
+		"":          false,
+		"013101000": false,
+		"01-":       false,
+		"0A":        false,
+	} {
+		assert.Equal(t, expected, ValidCEPPrefix(prefix), prefix)
+	}
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// MetricsFilter selects the carrier offers the metrics are calculated over. The quotes
+// are narrowed by the window and the zipcode prefixes to those with at least one offer
+// matching Carrier, Service, MinPrice and MaxPrice, and only the matching offers of
+// those quotes are counted. LastQuotes keeps only the latest of the matching quotes;
+// zero keeps them all.
+type MetricsFilter struct {
+	From                     *time.Time
+	To                       *time.Time
+	LastQuotes               int
+	Carrier                  string
+	Service                  string
+	OriginZipcodePrefix      string
+	DestinationZipcodePrefix string
+	MinPrice                 *Money
+	MaxPrice                 *Money
+}
+
+// MetricsWindow is the window the metrics were calculated over, as echoed back to
+// clients. From is inclusive and To exclusive; a nil bound leaves the window open.
+type MetricsWindow struct {
+	From       *time.Time `json:"from"`
+	To         *time.Time `json:"to"`
+	LastQuotes int        `json:"last_quotes,omitempty"`
+	Quotes     int        `json:"quotes"`
+}
+
+// Window returns the window of the filter, over the given number of matched quotes
+func (f MetricsFilter) Window(quotes int) MetricsWindow {
+	return MetricsWindow{From: f.From, To: f.To, LastQuotes: f.LastQuotes, Quotes: quotes}
+}
+
+// MatchesQuote reports whether the quote was created within the window and ships
+// between the zipcode prefixes. The offers of the quote are not checked.
+func (f MetricsFilter) MatchesQuote(quote Quote) bool {
+	if f.From != nil && quote.CreatedAt.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && !quote.CreatedAt.Before(*f.To) {
+		return false
+	}
+	if !strings.HasPrefix(string(quote.RecipientZipcode), f.DestinationZipcodePrefix) {
+		return false
+	}
+	if f.OriginZipcodePrefix == "" {
+		return true
+	}
+	for _, dispatcher := range quote.Dispatchers {
+		if strings.HasPrefix(string(dispatcher.Zipcode), f.OriginZipcodePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesCarrier reports whether the offer is of the carrier and service of the filter
+// and within its price band
+func (f MetricsFilter) MatchesCarrier(carrier Carrier) bool {
+	return (f.Carrier == "" || carrier.Name == f.Carrier) &&
+		(f.Service == "" || carrier.Service == f.Service) &&
+		(f.MinPrice == nil || carrier.Price >= *f.MinPrice) &&
+		(f.MaxPrice == nil || carrier.Price <= *f.MaxPrice)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsFilter_MatchesQuote(t *testing.T) {
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	quote := Quote{
+		RecipientZipcode: "01310100",
+		Dispatchers:      []QuoteDispatcher{{Zipcode: "29161376"}, {Zipcode: "88010000"}},
+		CreatedAt:        from,
+	}
+
+	tests := []struct {
+		name     string
+		filter   MetricsFilter
+		expected bool
+	}{
+		{name: "no filter", filter: MetricsFilter{}, expected: true},
+		{name: "within the window", filter: MetricsFilter{From: &from, To: &to}, expected: true},
+		{name: "at the end of the window", filter: MetricsFilter{To: &from}, expected: false},
+		{name: "destination prefix", filter: MetricsFilter{DestinationZipcodePrefix: "013"}, expected: true},
+		{name: "other destination prefix", filter: MetricsFilter{DestinationZipcodePrefix: "02"}, expected: false},
+		{name: "origin prefix of any dispatcher", filter: MetricsFilter{OriginZipcodePrefix: "88"}, expected: true},
+		{name: "other origin prefix", filter: MetricsFilter{OriginZipcodePrefix: "01"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.MatchesQuote(quote))
+		})
+	}
+}
+
+func TestMetricsFilter_MatchesCarrier(t *testing.T) {
+	minPrice, maxPrice := Money(1000), Money(2000)
+	filter := MetricsFilter{Carrier: "Correios", Service: "SEDEX", MinPrice: &minPrice, MaxPrice: &maxPrice}
+
+	assert.True(t, filter.MatchesCarrier(Carrier{Name: "Correios", Service: "SEDEX", Price: 1000}))
+	assert.True(t, filter.MatchesCarrier(Carrier{Name: "Correios", Service: "SEDEX", Price: 2000}))
+	assert.False(t, filter.MatchesCarrier(Carrier{Name: "Correios", Service: "PAC", Price: 1500}))
+	assert.False(t, filter.MatchesCarrier(Carrier{Name: "Jadlog", Service: "SEDEX", Price: 1500}))
+	assert.False(t, filter.MatchesCarrier(Carrier{Name: "Correios", Service: "SEDEX", Price: 2001}))
+}
@@ -2,56 +2,264 @@ package domain
 
 import "time"
 
+// QuoteRequest asks for offers to ship the volumes of the dispatchers to the recipient.
+// The options after SimulationType are optional and are forwarded to Frete Rápido as
+// given.
 type QuoteRequest struct {
-	Shipper        Shipper      `json:"shipper"`
-	Recipient      Recipient    `json:"recipient"`
-	Dispatchers    []Dispatcher `json:"dispatchers"`
-	SimulationType []int        `json:"simulation_type"`
+	Shipper        Shipper       `json:"shipper"`
+	Recipient      Recipient     `json:"recipient"`
+	Dispatchers    []Dispatcher  `json:"dispatchers"`
+	SimulationType []int         `json:"simulation_type"`
+	Channel        string        `json:"channel,omitempty"`
+	Identification string        `json:"identification,omitempty"`
+	Filter         int           `json:"filter,omitempty"`
+	Limit          int           `json:"limit,omitempty"`
+	Reverse        bool          `json:"reverse,omitempty"`
+	Returns        *QuoteReturns `json:"returns,omitempty"`
+}
+
+// QuoteReturns asks Frete Rápido to detail each offer with the composition of its
+// price, the volumes it carries and the freight rules applied to it
+type QuoteReturns struct {
+	Composition  bool `json:"composition"`
+	Volumes      bool `json:"volumes"`
+	AppliedRules bool `json:"applied_rules"`
 }
 
 type Shipper struct {
-	RegisteredNumber string `json:"registered_number"`
-	Token            string `json:"token"`
-	PlatformCode     string `json:"platform_code"`
+	RegisteredNumber RegisteredNumber `json:"registered_number"`
+	Token            string           `json:"token,omitempty"`
+	PlatformCode     string           `json:"platform_code"`
 }
 
 type Recipient struct {
-	Type    int    `json:"type"`
-	Country string `json:"country"`
-	Zipcode int    `json:"zipcode"`
+	Type             int              `json:"type"`
+	RegisteredNumber RegisteredNumber `json:"registered_number,omitempty"`
+	StateInscription string           `json:"state_inscription,omitempty"`
+	Country          string           `json:"country"`
+	Zipcode          CEP              `json:"zipcode"`
 }
 
+// Dispatcher is a place the volumes ship from. TotalPrice, when set, replaces the sum of
+// the unitary prices of the volumes as the value of the invoice.
 type Dispatcher struct {
-	RegisteredNumber string   `json:"registered_number"`
-	Zipcode          int      `json:"zipcode"`
-	Volumes          []Volume `json:"volumes"`
+	RegisteredNumber RegisteredNumber `json:"registered_number"`
+	Zipcode          CEP              `json:"zipcode"`
+	TotalPrice       Money            `json:"total_price,omitempty"`
+	Volumes          []Volume         `json:"volumes"`
 }
 
+// Volume is a product shipped by a dispatcher. Besides the measures, it takes the
+// optional attributes Frete Rápido accepts for a volume: AmountVolumes is the number of
+// packages each unit ships in, and Consolidate, Overlaid and Rotate tell whether the
+// units can share a package, be stacked and be turned over, which changes their cubage.
 type Volume struct {
 	Amount        int     `json:"amount"`
+	AmountVolumes int     `json:"amount_volumes,omitempty"`
 	Category      string  `json:"category"`
+	SKU           string  `json:"sku,omitempty"`
+	Tag           string  `json:"tag,omitempty"`
+	Description   string  `json:"description,omitempty"`
+	Manufacturer  string  `json:"manufacturer,omitempty"`
 	Height        float64 `json:"height"`
 	Width         float64 `json:"width"`
 	Length        float64 `json:"length"`
-	UnitaryPrice  float64 `json:"unitary_price"`
+	UnitaryPrice  Money   `json:"unitary_price"`
 	UnitaryWeight float64 `json:"unitary_weight"`
+	Consolidate   bool    `json:"consolidate,omitempty"`
+	Overlaid      bool    `json:"overlaid,omitempty"`
+	Rotate        bool    `json:"rotate,omitempty"`
 }
 
 type QuoteResponse struct {
-	Carrier []Carrier `json:"carrier"`
+	Carrier  []Carrier         `json:"carrier"`
+	Failures []ProviderFailure `json:"failures,omitempty"`
+}
+
+type ProviderFailure struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+type APIError struct {
+	Code           string           `json:"code"`
+	Message        string           `json:"message"`
+	UpstreamStatus int              `json:"upstream_status,omitempty"`
+	Details        []FieldViolation `json:"details,omitempty"`
+}
+
+// QuoteDetails is a stored quote as returned by the API
+type QuoteDetails struct {
+	ID                 uint         `json:"id"`
+	Request            QuoteRequest `json:"request"`
+	TotalWeight        float64      `json:"total_weight"`
+	TotalDeclaredValue Money        `json:"total_declared_value"`
+	Currency           Currency     `json:"currency"`
+	Carrier            []Carrier    `json:"carrier"`
+	CreatedAt          time.Time    `json:"created_at"`
+}
+
+type QuotePage struct {
+	Quotes     []QuoteDetails `json:"quotes"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 type Quote struct {
-	ID        uint      `gorm:"primaryKey"`
-	Carrier   []Carrier `gorm:"foreignKey:QuoteID"`
-	CreatedAt time.Time
+	ID                        uint             `gorm:"primaryKey"`
+	ShipperRegisteredNumber   RegisteredNumber `gorm:"index"`
+	ShipperPlatformCode       string
+	RecipientType             int
+	RecipientRegisteredNumber RegisteredNumber
+	RecipientStateInscription string
+	RecipientCountry          string
+	RecipientZipcode          CEP   `gorm:"size:8;index"`
+	SimulationType            []int `gorm:"serializer:json"`
+	Channel                   string
+	Identification            string
+	Filter                    int
+	Limit                     int
+	Reverse                   bool
+	Returns                   *QuoteReturns `gorm:"serializer:json"`
+	TotalWeight               float64
+	TotalDeclaredValue        Money             `gorm:"column:total_declared_value_cents"`
+	Currency                  Currency          `gorm:"size:3"`
+	Dispatchers               []QuoteDispatcher `gorm:"foreignKey:QuoteID"`
+	Carrier                   []Carrier         `gorm:"foreignKey:QuoteID"`
+	CreatedAt                 time.Time         `gorm:"index"`
+}
+
+type QuoteDispatcher struct {
+	ID               uint `gorm:"primaryKey"`
+	QuoteID          uint `gorm:"index"`
+	RegisteredNumber RegisteredNumber
+	Zipcode          CEP           `gorm:"size:8;index"`
+	TotalPrice       Money         `gorm:"column:total_price_cents"`
+	Volumes          []QuoteVolume `gorm:"foreignKey:QuoteDispatcherID"`
+}
+
+type QuoteVolume struct {
+	ID                uint `gorm:"primaryKey"`
+	QuoteDispatcherID uint `gorm:"index"`
+	Amount            int
+	AmountVolumes     int
+	Category          string
+	SKU               string `gorm:"index"`
+	Tag               string
+	Description       string
+	Manufacturer      string
+	Height            float64
+	Width             float64
+	Length            float64
+	UnitaryPrice      Money `gorm:"column:unitary_price_cents"`
+	UnitaryWeight     float64
+	Consolidate       bool
+	Overlaid          bool
+	Rotate            bool
 }
 
 type Carrier struct {
-	ID       uint    `gorm:"primaryKey"`
-	QuoteID  uint    `gorm:"index"`
-	Name     string  `json:"name"`
-	Service  string  `json:"service"`
-	Deadline int     `json:"deadline"`
-	Price    float64 `json:"price"`
+	ID                      uint       `gorm:"primaryKey" json:"-"`
+	QuoteID                 uint       `gorm:"index" json:"-"`
+	Name                    string     `json:"name"`
+	Service                 string     `json:"service"`
+	Deadline                int        `json:"deadline"`
+	Price                   Money      `gorm:"column:price_cents;index:idx_carriers_price_cents" json:"price"`
+	Currency                Currency   `gorm:"size:3" json:"currency"`
+	Provider                string     `json:"provider"`
+	CarrierRegisteredNumber string     `json:"carrier_registered_number"`
+	CarrierReference        int        `json:"carrier_reference"`
+	DispatcherID            string     `json:"dispatcher_id"`
+	OfferID                 int        `json:"offer_id"`
+	CostPrice               Money      `gorm:"column:cost_price_cents" json:"cost_price"`
+	CubicWeight             float64    `json:"cubic_weight"`
+	ExpiresAt               *time.Time `json:"expires_at"`
+	EstimatedDeliveryDate   *time.Time `json:"estimated_delivery_date"`
+}
+
+// Metrics summarizes the carrier offers of a set of quotes. Approximate tells that the
+// percentiles of the price and deadline stats were estimated, which happens when there
+// are too many offers to sort them all.
+type Metrics struct {
+	Window             MetricsWindow             `json:"window"`
+	Currency           Currency                  `json:"currency"`
+	Approximate        bool                      `json:"approximate"`
+	Carriers           map[string]CarrierMetrics `json:"carriers"`
+	CheapestQuote      *Carrier                  `json:"cheapest_quote"`
+	MostExpensiveQuote *Carrier                  `json:"most_expensive_quote"`
+	FastestQuote       *Carrier                  `json:"fastest_quote"`
+	SlowestQuote       *Carrier                  `json:"slowest_quote"`
+	CostVsSpeed        *CostVsSpeed              `json:"cost_vs_speed"`
+}
+
+// CarrierMetrics summarizes the offers of a carrier, in total and by service
+type CarrierMetrics struct {
+	Count           int                       `json:"count"`
+	TotalPrice      Money                     `json:"total_price"`
+	AveragePrice    Money                     `json:"average_price"`
+	AverageDeadline float64                   `json:"average_deadline"`
+	PriceStats      PriceStats                `json:"price_stats"`
+	DeadlineStats   DeadlineStats             `json:"deadline_stats"`
+	Services        map[string]ServiceMetrics `json:"services"`
+}
+
+// ServiceMetrics summarizes the offers of one service of a carrier
+type ServiceMetrics struct {
+	Count           int           `json:"count"`
+	TotalPrice      Money         `json:"total_price"`
+	AveragePrice    Money         `json:"average_price"`
+	AverageDeadline float64       `json:"average_deadline"`
+	PriceStats      PriceStats    `json:"price_stats"`
+	DeadlineStats   DeadlineStats `json:"deadline_stats"`
+}
+
+// PriceStats describes the spread of the prices of a set of offers. The percentiles
+// are interpolated between the closest prices and StdDev is the standard deviation of
+// the population, all rounded half to even to the centavo.
+type PriceStats struct {
+	Min    Money `json:"min"`
+	Max    Money `json:"max"`
+	Median Money `json:"median"`
+	P90    Money `json:"p90"`
+	P95    Money `json:"p95"`
+	StdDev Money `json:"std_dev"`
+}
+
+// CostVsSpeed weighs what the services faster than the cheapest one charge for each day
+// of delivery they save, comparing their average prices and deadlines. Services are
+// sorted by the price per day saved, the best deal first.
+type CostVsSpeed struct {
+	Baseline ServiceSummary `json:"baseline"`
+	Services []SpeedPremium `json:"services"`
+}
+
+// ServiceSummary is the average price and deadline of a service of a carrier
+type ServiceSummary struct {
+	Carrier         string  `json:"carrier"`
+	Service         string  `json:"service"`
+	AveragePrice    Money   `json:"average_price"`
+	AverageDeadline float64 `json:"average_deadline"`
+}
+
+// SpeedPremium is how much more than the baseline a faster service charges, in total
+// and per day of delivery saved
+type SpeedPremium struct {
+	ServiceSummary
+	ExtraPrice       Money   `json:"extra_price"`
+	DaysSaved        float64 `json:"days_saved"`
+	PricePerDaySaved Money   `json:"price_per_day_saved"`
+}
+
+// DeadlineStats describes the spread of the deadlines, in days, of a set of offers,
+// calculated like PriceStats and rounded to two decimal places
+type DeadlineStats struct {
+	Min    int     `json:"min"`
+	Max    int     `json:"max"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	P95    float64 `json:"p95"`
+	StdDev float64 `json:"std_dev"`
 }
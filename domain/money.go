@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// CurrencyBRL is the currency of every amount handled by the service
+const CurrencyBRL Currency = "BRL"
+
+var ErrInvalidMoney = errors.New("invalid amount of money")
+
+// Money is an exact amount in centavos. It is written in JSON as a number of reais with
+// two decimal places, such as 34.50, and stored in the database as an integer.
+type Money int64
+
+// ParseMoney parses a decimal amount of reais, such as "34.5" or "1e2", without going
+// through floating point. Amounts with fractions of a centavo are rounded half to even,
+// as prescribed by ABNT NBR 5891.
+func ParseMoney(value string) (Money, error) {
+	amount, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+	amount.Mul(amount, big.NewRat(100, 1))
+
+	quotient, remainder := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+	// the remainder has the sign of the numerator, so compare magnitudes to find out
+	// whether the amount is past the middle of two centavos
+	half := new(big.Int).Abs(remainder)
+	half.Lsh(half, 1)
+	switch half.Cmp(amount.Denom()) {
+	case 1:
+		quotient.Add(quotient, big.NewInt(int64(amount.Sign())))
+	case 0:
+		if quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(int64(amount.Sign())))
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidMoney, value)
+	}
+	return Money(quotient.Int64()), nil
+}
+
+// Mul returns the amount multiplied by n
+func (m Money) Mul(n int) Money {
+	return m * Money(n)
+}
+
+// Div returns the amount divided by a positive n, rounded half to even
+func (m Money) Div(n int) Money {
+	quotient, remainder := m/Money(n), m%Money(n)
+	if remainder < 0 {
+		remainder = -remainder
+	}
+
+	if twice := 2 * remainder; twice > Money(n) || (twice == Money(n) && quotient%2 != 0) {
+		if m < 0 {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return quotient
+}
+
+// String formats the amount as a decimal number of reais, such as "-12.05"
+func (m Money) String() string {
+	sign, cents := "", uint64(m)
+	if m < 0 {
+		sign, cents = "-", uint64(-m)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads the amount from the text of a JSON number, so that it is never
+// rounded to the nearest float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) == 0 || (data[0] != '-' && (data[0] < '0' || data[0] > '9')) {
+		return &json.UnmarshalTypeError{Value: jsonValueKind(data), Type: reflect.TypeOf(m).Elem()}
+	}
+
+	parsed, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// jsonValueKind names the kind of a JSON value the way encoding/json does in its errors
+func jsonValueKind(data []byte) string {
+	switch {
+	case len(data) == 0:
+		return "value"
+	case data[0] == '"':
+		return "string"
+	case data[0] == '{':
+		return "object"
+	case data[0] == '[':
+		return "array"
+	case data[0] == 't' || data[0] == 'f':
+		return "bool"
+	default:
+		return "value"
+	}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Money
+		wantErr  bool
+	}{
+		{value: "34", expected: 3400},
+		{value: "34.5", expected: 3450},
+		{value: "0.1", expected: 10},
+		{value: "-12.05", expected: -1205},
+		{value: "1e2", expected: 10000},
+		{value: "34.00000000001", expected: 3400},
+		{value: "0.125", expected: 12},
+		{value: "0.135", expected: 14},
+		{value: "0.1251", expected: 13},
+		{value: "-0.125", expected: -12},
+		{value: "-0.135", expected: -14},
+		{value: "R$ 10", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "1e30", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			amount, err := ParseMoney(tt.value)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidMoney)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, amount)
+		})
+	}
+}
+
+func TestMoney_Div(t *testing.T) {
+	assert.Equal(t, Money(1001), Money(3002).Div(3))
+	assert.Equal(t, Money(2), Money(5).Div(2), "half is rounded to even")
+	assert.Equal(t, Money(4), Money(7).Div(2), "half is rounded to even")
+	assert.Equal(t, Money(-4), Money(-7).Div(2))
+	assert.Equal(t, Money(-3), Money(-10).Div(3))
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "34.50", Money(3450).String())
+	assert.Equal(t, "0.05", Money(5).String())
+	assert.Equal(t, "-0.05", Money(-5).String())
+	assert.Equal(t, "-12.00", Money(-1200).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var volume Volume
+	assert.NoError(t, json.Unmarshal([]byte(`{"unitary_price": 0.3}`), &volume))
+	assert.Equal(t, Money(30), volume.UnitaryPrice)
+
+	data, err := json.Marshal(volume)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"unitary_price":0.30`)
+
+	var typeErr *json.UnmarshalTypeError
+	assert.ErrorAs(t, json.Unmarshal([]byte(`{"unitary_price": "0.3"}`), &volume), &typeErr)
+	assert.Error(t, json.Unmarshal([]byte(`{"unitary_price": 1e30}`), &volume))
+}
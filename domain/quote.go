@@ -0,0 +1,138 @@
+package domain
+
+// NewQuote builds the Quote stored for a request and the carriers offered for it.
+// The shipper token is a secret and is never stored.
+func NewQuote(input QuoteRequest, carriers []Carrier) Quote {
+	quote := Quote{
+		ShipperRegisteredNumber:   input.Shipper.RegisteredNumber,
+		ShipperPlatformCode:       input.Shipper.PlatformCode,
+		RecipientType:             input.Recipient.Type,
+		RecipientRegisteredNumber: input.Recipient.RegisteredNumber,
+		RecipientStateInscription: input.Recipient.StateInscription,
+		RecipientCountry:          input.Recipient.Country,
+		RecipientZipcode:          input.Recipient.Zipcode,
+		SimulationType:            input.SimulationType,
+		Channel:                   input.Channel,
+		Identification:            input.Identification,
+		Filter:                    input.Filter,
+		Limit:                     input.Limit,
+		Reverse:                   input.Reverse,
+		Returns:                   input.Returns,
+		Currency:                  CurrencyBRL,
+		Dispatchers:               make([]QuoteDispatcher, 0, len(input.Dispatchers)),
+		Carrier:                   carriers,
+	}
+
+	for _, dispatcher := range input.Dispatchers {
+		quoteDispatcher := QuoteDispatcher{
+			RegisteredNumber: dispatcher.RegisteredNumber,
+			Zipcode:          dispatcher.Zipcode,
+			TotalPrice:       dispatcher.TotalPrice,
+			Volumes:          make([]QuoteVolume, 0, len(dispatcher.Volumes)),
+		}
+
+		for _, volume := range dispatcher.Volumes {
+			quote.TotalWeight += float64(volume.Amount) * volume.UnitaryWeight
+			quote.TotalDeclaredValue += volume.UnitaryPrice.Mul(volume.Amount)
+
+			quoteDispatcher.Volumes = append(quoteDispatcher.Volumes, QuoteVolume{
+				Amount:        volume.Amount,
+				AmountVolumes: volume.AmountVolumes,
+				Category:      volume.Category,
+				SKU:           volume.SKU,
+				Tag:           volume.Tag,
+				Description:   volume.Description,
+				Manufacturer:  volume.Manufacturer,
+				Height:        volume.Height,
+				Width:         volume.Width,
+				Length:        volume.Length,
+				UnitaryPrice:  volume.UnitaryPrice,
+				UnitaryWeight: volume.UnitaryWeight,
+				Consolidate:   volume.Consolidate,
+				Overlaid:      volume.Overlaid,
+				Rotate:        volume.Rotate,
+			})
+		}
+
+		quote.Dispatchers = append(quote.Dispatchers, quoteDispatcher)
+	}
+
+	return quote
+}
+
+// Request rebuilds the request that produced the quote so it can be audited or re-run.
+// The shipper token is not stored, so it must be filled in before sending it again.
+func (q Quote) Request() QuoteRequest {
+	request := QuoteRequest{
+		Shipper: Shipper{
+			RegisteredNumber: q.ShipperRegisteredNumber,
+			PlatformCode:     q.ShipperPlatformCode,
+		},
+		Recipient: Recipient{
+			Type:             q.RecipientType,
+			RegisteredNumber: q.RecipientRegisteredNumber,
+			StateInscription: q.RecipientStateInscription,
+			Country:          q.RecipientCountry,
+			Zipcode:          q.RecipientZipcode,
+		},
+		Dispatchers:    make([]Dispatcher, 0, len(q.Dispatchers)),
+		SimulationType: q.SimulationType,
+		Channel:        q.Channel,
+		Identification: q.Identification,
+		Filter:         q.Filter,
+		Limit:          q.Limit,
+		Reverse:        q.Reverse,
+		Returns:        q.Returns,
+	}
+
+	for _, quoteDispatcher := range q.Dispatchers {
+		dispatcher := Dispatcher{
+			RegisteredNumber: quoteDispatcher.RegisteredNumber,
+			Zipcode:          quoteDispatcher.Zipcode,
+			TotalPrice:       quoteDispatcher.TotalPrice,
+			Volumes:          make([]Volume, 0, len(quoteDispatcher.Volumes)),
+		}
+
+		for _, volume := range quoteDispatcher.Volumes {
+			dispatcher.Volumes = append(dispatcher.Volumes, Volume{
+				Amount:        volume.Amount,
+				AmountVolumes: volume.AmountVolumes,
+				Category:      volume.Category,
+				SKU:           volume.SKU,
+				Tag:           volume.Tag,
+				Description:   volume.Description,
+				Manufacturer:  volume.Manufacturer,
+				Height:        volume.Height,
+				Width:         volume.Width,
+				Length:        volume.Length,
+				UnitaryPrice:  volume.UnitaryPrice,
+				UnitaryWeight: volume.UnitaryWeight,
+				Consolidate:   volume.Consolidate,
+				Overlaid:      volume.Overlaid,
+				Rotate:        volume.Rotate,
+			})
+		}
+
+		request.Dispatchers = append(request.Dispatchers, dispatcher)
+	}
+
+	return request
+}
+
+// Details returns the quote as exposed by the API
+func (q Quote) Details() QuoteDetails {
+	carriers := q.Carrier
+	if carriers == nil {
+		carriers = []Carrier{}
+	}
+
+	return QuoteDetails{
+		ID:                 q.ID,
+		Request:            q.Request(),
+		TotalWeight:        q.TotalWeight,
+		TotalDeclaredValue: q.TotalDeclaredValue,
+		Currency:           q.Currency,
+		Carrier:            carriers,
+		CreatedAt:          q.CreatedAt,
+	}
+}
@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultQuotePageSize = 20
+	MaxQuotePageSize     = 100
+)
+
+// ErrQuoteNotFound is returned when no quote has the requested ID
+var ErrQuoteNotFound = errors.New("quote not found")
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be used
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// QuoteSortField is a field quotes can be listed by
+type QuoteSortField string
+
+const (
+	SortByCreatedAt          QuoteSortField = "created_at"
+	SortByTotalWeight        QuoteSortField = "total_weight"
+	SortByTotalDeclaredValue QuoteSortField = "total_declared_value"
+)
+
+// QuoteSort is the order quotes are listed in. Quotes with the same value are
+// ordered by ID in the same direction.
+type QuoteSort struct {
+	Field      QuoteSortField
+	Descending bool
+}
+
+// DefaultQuoteSort lists the newest quotes first
+var DefaultQuoteSort = QuoteSort{Field: SortByCreatedAt, Descending: true}
+
+// ParseQuoteSort parses a sort such as "created_at" or "-total_weight", where the
+// leading minus sign means descending order
+func ParseQuoteSort(value string) (QuoteSort, error) {
+	if value == "" {
+		return DefaultQuoteSort, nil
+	}
+
+	sort := QuoteSort{Field: QuoteSortField(strings.TrimPrefix(value, "-"))}
+	sort.Descending = strings.HasPrefix(value, "-")
+
+	switch sort.Field {
+	case SortByCreatedAt, SortByTotalWeight, SortByTotalDeclaredValue:
+		return sort, nil
+	default:
+		return QuoteSort{}, fmt.Errorf("invalid sort %q", value)
+	}
+}
+
+func (s QuoteSort) String() string {
+	if s.Descending {
+		return "-" + string(s.Field)
+	}
+	return string(s.Field)
+}
+
+// QuoteFilter selects the quotes to list. Carrier, Service, MinPrice and MaxPrice must
+// all match the same carrier offer of a quote.
+type QuoteFilter struct {
+	From             *time.Time
+	To               *time.Time
+	Carrier          string
+	Service          string
+	MinPrice         *Money
+	MaxPrice         *Money
+	RecipientZipcode CEP
+	Sort             QuoteSort
+	Limit            int
+	After            *QuoteCursor
+}
+
+// QuoteCursor points at the last quote of a page, in the sort the page was listed by
+type QuoteCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"i"`
+}
+
+// NewQuoteCursor creates the cursor for the page that follows quote
+func NewQuoteCursor(quote Quote, sort QuoteSort) QuoteCursor {
+	cursor := QuoteCursor{Sort: sort.String(), ID: quote.ID}
+
+	switch sort.Field {
+	case SortByTotalWeight:
+		cursor.Value = strconv.FormatFloat(quote.TotalWeight, 'g', -1, 64)
+	case SortByTotalDeclaredValue:
+		cursor.Value = strconv.FormatInt(int64(quote.TotalDeclaredValue), 10)
+	default:
+		cursor.Value = quote.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
+
+// Encode returns the opaque representation of the cursor sent to clients
+func (c QuoteCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeQuoteCursor parses a cursor returned by Encode, checking that it was created
+// for sort
+func DecodeQuoteCursor(value string, sort QuoteSort) (*QuoteCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor QuoteCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort.String() {
+		return nil, fmt.Errorf("%w: it was created for sort %q", ErrInvalidCursor, cursor.Sort)
+	}
+	if _, err := cursor.SortValue(sort); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// SortValue returns the typed value of the sort field the cursor points at
+func (c QuoteCursor) SortValue(sort QuoteSort) (interface{}, error) {
+	switch sort.Field {
+	case SortByCreatedAt:
+		return time.Parse(time.RFC3339Nano, c.Value)
+	case SortByTotalDeclaredValue:
+		cents, err := strconv.ParseInt(c.Value, 10, 64)
+		return Money(cents), err
+	default:
+		return strconv.ParseFloat(c.Value, 64)
+	}
+}
@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuoteSort(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected QuoteSort
+		wantErr  string
+	}{
+		{value: "", expected: DefaultQuoteSort},
+		{value: "created_at", expected: QuoteSort{Field: SortByCreatedAt}},
+		{value: "-total_weight", expected: QuoteSort{Field: SortByTotalWeight, Descending: true}},
+		{value: "total_declared_value", expected: QuoteSort{Field: SortByTotalDeclaredValue}},
+		{value: "-price", wantErr: `invalid sort "-price"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			sort, err := ParseQuoteSort(tt.value)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sort)
+		})
+	}
+}
+
+func TestQuoteCursor(t *testing.T) {
+	createdAt := time.Date(2024, 6, 12, 10, 30, 0, 123000000, time.UTC)
+	quote := Quote{ID: 42, TotalWeight: 13.5, CreatedAt: createdAt}
+
+	t.Run("round trip by created_at", func(t *testing.T) {
+		encoded := NewQuoteCursor(quote, DefaultQuoteSort).Encode()
+
+		cursor, err := DecodeQuoteCursor(encoded, DefaultQuoteSort)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(42), cursor.ID)
+
+		value, err := cursor.SortValue(DefaultQuoteSort)
+		assert.NoError(t, err)
+		assert.True(t, createdAt.Equal(value.(time.Time)))
+	})
+
+	t.Run("round trip by total_weight", func(t *testing.T) {
+		sort := QuoteSort{Field: SortByTotalWeight}
+		cursor, err := DecodeQuoteCursor(NewQuoteCursor(quote, sort).Encode(), sort)
+		assert.NoError(t, err)
+
+		value, err := cursor.SortValue(sort)
+		assert.NoError(t, err)
+		assert.Equal(t, 13.5, value)
+	})
+
+	t.Run("cursor of another sort", func(t *testing.T) {
+		encoded := NewQuoteCursor(quote, DefaultQuoteSort).Encode()
+
+		_, err := DecodeQuoteCursor(encoded, QuoteSort{Field: SortByCreatedAt})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("malformed cursor", func(t *testing.T) {
+		_, err := DecodeQuoteCursor("not a cursor", DefaultQuoteSort)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+}
@@ -0,0 +1,22 @@
+package domain
+
+// QuoteLimits bound the volumes of a quote request, so that requests no carrier can
+// take are rejected before reaching the providers. Dimensions are in meters, weights in
+// kilograms and the limits of the unitary values apply to each unit. A zero limit is
+// not enforced.
+type QuoteLimits struct {
+	MaxDimension      float64
+	MaxVolumeWeight   float64
+	MaxVolumeValue    Money
+	MaxShipmentWeight float64
+	MaxShipmentValue  Money
+}
+
+// DefaultQuoteLimits fit the largest volumes taken by road carriers
+var DefaultQuoteLimits = QuoteLimits{
+	MaxDimension:      3,
+	MaxVolumeWeight:   1000,
+	MaxVolumeValue:    10_000_000,
+	MaxShipmentWeight: 30_000,
+	MaxShipmentValue:  100_000_000,
+}
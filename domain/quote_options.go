@@ -0,0 +1,27 @@
+package domain
+
+// Types of recipient accepted in Recipient.Type
+const (
+	RecipientTypeIndividual = 0
+	RecipientTypeCompany    = 1
+)
+
+// Simulation types accepted in QuoteRequest.SimulationType: fractional shipments share
+// the vehicle with other loads, full loads take a whole vehicle
+const (
+	SimulationTypeFractional = 0
+	SimulationTypeFullLoad   = 1
+)
+
+// Filters accepted in QuoteRequest.Filter. Without a filter every offer is returned.
+const (
+	QuoteFilterCheapest = 1
+	QuoteFilterFastest  = 2
+)
+
+// Lengths of the free text options of a QuoteRequest accepted by Frete Rápido
+const (
+	MaxChannelLength          = 255
+	MaxIdentificationLength   = 255
+	MaxStateInscriptionLength = 14
+)
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewQuote(t *testing.T) {
+	input := QuoteRequest{
+		Shipper: Shipper{
+			RegisteredNumber: "25438296000158",
+			Token:            "secret",
+			PlatformCode:     "5AKVkHqCn",
+		},
+		Recipient: Recipient{
+			Type:             RecipientTypeCompany,
+			RegisteredNumber: "04884082000135",
+			StateInscription: "ISENTO",
+			Country:          "BRA",
+			Zipcode:          "29161376",
+		},
+		Dispatchers: []Dispatcher{
+			{
+				RegisteredNumber: "25438296000158",
+				Zipcode:          "29161376",
+				TotalPrice:       150000,
+				Volumes: []Volume{
+					{Category: "7", Amount: 1, UnitaryWeight: 5, UnitaryPrice: 34900, Height: 0.2, Width: 0.2, Length: 0.2,
+						SKU: "abc-teste-123", Tag: "promo", Description: "Caneca", Manufacturer: "ACME", AmountVolumes: 2, Consolidate: true, Overlaid: true, Rotate: true},
+					{Category: "7", Amount: 2, UnitaryWeight: 4, UnitaryPrice: 55600, Height: 0.4, Width: 0.6, Length: 0.15},
+				},
+			},
+		},
+		SimulationType: []int{SimulationTypeFractional},
+		Channel:        "marketplace",
+		Identification: "pedido-123",
+		Filter:         QuoteFilterFastest,
+		Limit:          5,
+		Reverse:        true,
+		Returns:        &QuoteReturns{Composition: true, AppliedRules: true},
+	}
+	carriers := []Carrier{{Name: "Carrier1", Price: 1000}}
+
+	quote := NewQuote(input, carriers)
+
+	assert.Equal(t, RegisteredNumber("25438296000158"), quote.ShipperRegisteredNumber)
+	assert.Equal(t, CEP("29161376"), quote.RecipientZipcode)
+	assert.Equal(t, 13.0, quote.TotalWeight)
+	assert.Equal(t, Money(146100), quote.TotalDeclaredValue)
+	assert.Equal(t, CurrencyBRL, quote.Currency)
+	assert.Len(t, quote.Dispatchers, 1)
+	assert.Len(t, quote.Dispatchers[0].Volumes, 2)
+	assert.Equal(t, "abc-teste-123", quote.Dispatchers[0].Volumes[0].SKU)
+	assert.Equal(t, carriers, quote.Carrier)
+
+	expectedRequest := input
+	expectedRequest.Shipper.Token = ""
+	assert.Equal(t, expectedRequest, quote.Request())
+}
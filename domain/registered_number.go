@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RegisteredNumber is a Brazilian taxpayer number: the CNPJ of a company or the CPF of a
+// person. It is kept without the mask, as in 25438296000158.
+type RegisteredNumber string
+
+// registeredNumberMask are the separators of masked numbers, as in 25.438.296/0001-58
+var registeredNumberMask = strings.NewReplacer(".", "", "/", "", "-", "", " ", "")
+
+// NormalizeRegisteredNumber removes the mask of a CNPJ or CPF and upper cases the letters
+// of an alphanumeric CNPJ
+func NormalizeRegisteredNumber(value string) RegisteredNumber {
+	return RegisteredNumber(strings.ToUpper(registeredNumberMask.Replace(value)))
+}
+
+// Valid reports whether the number is a CNPJ or a CPF with the right check digits
+func (n RegisteredNumber) Valid() bool {
+	return n.IsCNPJ() || n.IsCPF()
+}
+
+// IsCNPJ reports whether the number is a valid CNPJ. Besides the numeric format, it
+// accepts the alphanumeric one introduced in July 2026, whose 12 first characters may be
+// letters. Every character counts as its ASCII code minus 48 towards the check digits.
+func (n RegisteredNumber) IsCNPJ() bool {
+	if len(n) != 14 || allSame(n) {
+		return false
+	}
+	for i := 0; i < 14; i++ {
+		c := n[i]
+		if !isDigit(c) && (i >= 12 || c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+
+	weights := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	return checkDigit(n[:12], weights[1:]) == n[12] && checkDigit(n[:13], weights) == n[13]
+}
+
+// IsCPF reports whether the number is a valid CPF
+func (n RegisteredNumber) IsCPF() bool {
+	if len(n) != 11 || allSame(n) {
+		return false
+	}
+	for i := 0; i < 11; i++ {
+		if !isDigit(n[i]) {
+			return false
+		}
+	}
+
+	weights := []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}
+	return checkDigit(n[:9], weights[1:]) == n[9] && checkDigit(n[:10], weights) == n[10]
+}
+
+// UnmarshalJSON reads the number with or without its mask
+func (n *RegisteredNumber) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	*n = NormalizeRegisteredNumber(value)
+	return nil
+}
+
+// checkDigit calculates the modulo 11 check digit of value with the given weights
+func checkDigit(value RegisteredNumber, weights []int) byte {
+	sum := 0
+	for i, weight := range weights {
+		sum += int(value[i]-'0') * weight
+	}
+
+	remainder := sum % 11
+	if remainder < 2 {
+		return '0'
+	}
+	return byte('0' + 11 - remainder)
+}
+
+// allSame reports whether every character is the same, as in 00000000000, which passes
+// the check digits but is not a valid number
+func allSame(value RegisteredNumber) bool {
+	return strings.Count(string(value), string(value[0])) == len(value)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisteredNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		cnpj  bool
+		cpf   bool
+	}{
+		{value: "25438296000158", cnpj: true},
+		{value: "25.438.296/0001-58", cnpj: true},
+		{value: "04884082000135", cnpj: true},
+		{value: "12.ABC.345/01DE-35", cnpj: true},
+		{value: "12.abc.345/01de-35", cnpj: true},
+		{value: "529.982.247-25", cpf: true},
+		{value: "52998224725", cpf: true},
+		{value: "25438296000159"},
+		{value: "12ABC34501DE36"},
+		{value: "12ABC34501DEA5"},
+		{value: "52998224724"},
+		{value: "00000000000000"},
+		{value: "11111111111"},
+		{value: "123456789"},
+		{value: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			number := NormalizeRegisteredNumber(tt.value)
+
+			assert.Equal(t, tt.cnpj, number.IsCNPJ())
+			assert.Equal(t, tt.cpf, number.IsCPF())
+			assert.Equal(t, tt.cnpj || tt.cpf, number.Valid())
+		})
+	}
+}
+
+func TestRegisteredNumber_UnmarshalJSON(t *testing.T) {
+	var shipper Shipper
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"registered_number": "12.abc.345/01de-35"}`), &shipper))
+	assert.Equal(t, RegisteredNumber("12ABC34501DE35"), shipper.RegisteredNumber)
+	assert.Error(t, json.Unmarshal([]byte(`{"registered_number": 25438296000158}`), &shipper))
+}
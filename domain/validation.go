@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules a field of a request can violate, reported in FieldViolation.Rule
+const (
+	RuleRequired         = "required"
+	RuleMinItems         = "min_items"
+	RulePositive         = "positive"
+	RuleMax              = "max"
+	RuleCategory         = "category"
+	RuleOneOf            = "one_of"
+	RuleMaxLength        = "max_length"
+	RuleStateInscription = "state_inscription"
+	RuleCEP              = "cep"
+	RuleRegisteredNumber = "cnpj_cpf"
+)
+
+// FieldViolation is a rule broken by a field of a request. Field is the JSON pointer of
+// the field, such as /dispatchers/0/volumes/2/unitary_weight.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError holds every rule a request breaks
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, fmt.Sprintf("%s %s", violation.Field, violation.Message))
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Add records that field broke rule
+func (e *ValidationError) Add(field, rule, message string) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Rule: rule, Message: message})
+}
+
+// Err returns the error when a rule was broken, or nil otherwise
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,77 @@
+package domain
+
+// VolumeCategories are the volume categories accepted by Frete Rápido, by code. See
+// https://dev.freterapido.com/common/tipos_de_volumes/
+var VolumeCategories = map[string]string{
+	"1":   "Abrasivos",
+	"2":   "Adubos / Fertilizantes",
+	"3":   "Alimentos perecíveis",
+	"4":   "Artigos para Pesca",
+	"5":   "Auto Peças",
+	"6":   "Bebidas / Destilados",
+	"7":   "Brindes",
+	"8":   "Brinquedos",
+	"9":   "Calçados",
+	"10":  "CD / DVD / Blu-Ray",
+	"11":  "Combustíveis / Óleos",
+	"12":  "Confecção",
+	"13":  "Cosméticos",
+	"14":  "Couro",
+	"15":  "Derivados Petróleo",
+	"16":  "Descartáveis",
+	"17":  "Editorial",
+	"18":  "Eletrônicos",
+	"19":  "Eletrodomésticos",
+	"20":  "Embalagens",
+	"21":  "Explosivos / Pirotécnicos",
+	"22":  "Medicamentos",
+	"23":  "Ferragens",
+	"24":  "Ferramentas",
+	"25":  "Fibras Ópticas",
+	"26":  "Fonográfico",
+	"27":  "Fotográfico",
+	"28":  "Fraldas / Geriátricas",
+	"29":  "Higiene / Limpeza",
+	"30":  "Impressos",
+	"31":  "Informática / Computadores",
+	"32":  "Instrumento Musical",
+	"33":  "Livro(s)",
+	"34":  "Materiais Escritório",
+	"35":  "Materiais Esportivos",
+	"36":  "Materiais Frágeis",
+	"37":  "Material de Construção",
+	"38":  "Material de Irrigação",
+	"39":  "Material Elétrico / Lâmpada(s)",
+	"40":  "Material Gráfico",
+	"41":  "Material Hospitalar",
+	"42":  "Material Odontológico",
+	"43":  "Material Pet Shop / Rações",
+	"44":  "Material Veterinário",
+	"45":  "Móveis montados",
+	"46":  "Moto Peças",
+	"47":  "Mudas / Plantas",
+	"48":  "Papelaria / Documentos",
+	"49":  "Perfumaria",
+	"50":  "Material Plástico",
+	"51":  "Pneus e Borracharia",
+	"52":  "Produtos Cerâmicos",
+	"53":  "Produtos Químicos",
+	"54":  "Produtos Veterinários",
+	"55":  "Revistas",
+	"56":  "Sementes",
+	"57":  "Suprimentos Agrícolas / Rurais",
+	"58":  "Têxtil",
+	"59":  "Vacinas",
+	"60":  "Vestuário",
+	"61":  "Vidros / Frágil",
+	"62":  "Cargas refrigeradas / congeladas",
+	"63":  "Papelão",
+	"64":  "Móveis desmontados",
+	"999": "Outros",
+}
+
+// ValidVolumeCategory reports whether code is a Frete Rápido volume category
+func ValidVolumeCategory(code string) bool {
+	_, ok := VolumeCategories[code]
+	return ok
+}
@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidVolumeCategory(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected bool
+	}{
+		{code: "1", expected: true},
+		{code: "7", expected: true},
+		{code: "64", expected: true},
+		{code: "999", expected: true},
+		{code: "0", expected: false},
+		{code: "65", expected: false},
+		{code: "07", expected: false},
+		{code: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ValidVolumeCategory(tt.code))
+		})
+	}
+}
@@ -1,43 +1,134 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/belmadge/freteRapido/config"
-	"github.com/belmadge/freteRapido/domain"
+	"github.com/glebarez/sqlite"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
+const (
+	// memoryDSN is a SQLite database that lives only while the service runs
+	memoryDSN = "file:frete_rapido?mode=memory&cache=shared"
+
+	connectBaseDelay = 500 * time.Millisecond
+	connectMaxDelay  = 5 * time.Second
+)
+
 var DB *gorm.DB
 
-func InitDB() {
-	setupDatabaseConnection()
-	autoMigrateModels()
-}
+// InitDB connects to the configured database, retrying until DB_CONNECT_TIMEOUT expires,
+// and tunes its connection pool. An in-memory database is also migrated.
+func InitDB() error {
+	dialector, err := newDialector()
+	if err != nil {
+		return err
+	}
 
-func setupDatabaseConnection() {
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.Config.DBUser,
-		config.Config.DBPassword,
-		config.Config.DBHost,
-		config.Config.DBPort,
-		config.Config.DBName,
-	)
-
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	ctx, cancel := context.WithTimeout(context.Background(), config.Config.DBConnectTimeout)
+	defer cancel()
+
+	DB, err = openWithRetry(ctx, dialector)
 	if err != nil {
-		logrus.Error("failed to connect to database:", err)
-		return
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := configurePool(DB); err != nil {
+		return fmt.Errorf("failed to configure database pool: %w", err)
+	}
+
+	return migrateMemoryDatabase()
+}
+
+// openWithRetry opens the database, retrying with exponential backoff while ctx is alive
+func openWithRetry(ctx context.Context, dialector gorm.Dialector) (*gorm.DB, error) {
+	delay := connectBaseDelay
+	for attempt := 1; ; attempt++ {
+		db, err := gorm.Open(dialector, newGormConfig())
+		if err == nil {
+			return db, nil
+		}
+
+		logrus.Warnf("database connection attempt %d failed, retrying in %s: %s", attempt, delay, err.Error())
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > connectMaxDelay {
+			delay = connectMaxDelay
+		}
 	}
 }
 
-func autoMigrateModels() {
-	err := DB.AutoMigrate(&domain.Quote{}, &domain.Carrier{})
+// newGormConfig fills the creation times in UTC. SQLite stores times as text with their
+// offset and compares them as text, so every time is stored and queried in UTC.
+func newGormConfig() *gorm.Config {
+	return &gorm.Config{NowFunc: func() time.Time { return time.Now().UTC() }}
+}
+
+func configurePool(db *gorm.DB) error {
+	sqlDB, err := db.DB()
 	if err != nil {
-		logrus.Error("failed to auto-migrate database models:", err)
+		return err
+	}
+
+	if config.Config.DBDriver == config.DBDriverMemory {
+		// the in-memory database is dropped once its last connection is closed and shared
+		// cache connections fail with SQLITE_LOCKED on concurrent writes, so the whole
+		// service shares a single connection that is never recycled
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		return nil
 	}
+
+	sqlDB.SetMaxOpenConns(config.Config.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.Config.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.Config.DBConnMaxLifetime)
+	return nil
+}
+
+// newDialector returns the GORM dialector of the configured database driver
+func newDialector() (gorm.Dialector, error) {
+	switch config.Config.DBDriver {
+	case config.DBDriverMySQL:
+		dsn := fmt.Sprintf(
+			"%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
+			config.Config.DBUser,
+			config.Config.DBPassword,
+			config.Config.DBHost,
+			config.Config.DBPort,
+			config.Config.DBName,
+		)
+		return mysql.Open(dsn), nil
+	case config.DBDriverSQLite:
+		return sqlite.Open(config.Config.DBPath), nil
+	case config.DBDriverMemory:
+		return sqlite.Open(memoryDSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", config.Config.DBDriver)
+	}
+}
+
+// migrateMemoryDatabase brings an in-memory database, which is always created empty,
+// up to date. Other databases are migrated with the migrate command.
+func migrateMemoryDatabase() error {
+	if config.Config.DBDriver != config.DBDriverMemory {
+		return nil
+	}
+
+	if _, err := NewMigrator(DB, Migrations).Up(context.Background()); err != nil {
+		return fmt.Errorf("failed to migrate in-memory database: %w", err)
+	}
+
+	return nil
 }
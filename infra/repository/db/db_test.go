@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/config"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenWithRetry_GivesUpWhenContextExpires(t *testing.T) {
+	unreachable := sqlite.Open(filepath.Join(t.TempDir(), "missing", "frete_rapido.db"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	db, err := openWithRetry(ctx, unreachable)
+
+	assert.Error(t, err)
+	assert.Nil(t, db)
+	assert.Less(t, time.Since(start), connectBaseDelay+time.Second)
+}
+
+func TestInitDB(t *testing.T) {
+	original := config.Config
+	originalDB := DB
+	t.Cleanup(func() {
+		config.Config = original
+		DB = originalDB
+	})
+
+	t.Run("unsupported driver", func(t *testing.T) {
+		config.Config.DBDriver = "postgres"
+
+		err := InitDB()
+
+		assert.EqualError(t, err, `unsupported database driver "postgres"`)
+	})
+
+	t.Run("sqlite with pool settings", func(t *testing.T) {
+		config.Config.DBDriver = config.DBDriverSQLite
+		config.Config.DBPath = filepath.Join(t.TempDir(), "frete_rapido.db")
+		config.Config.DBConnectTimeout = time.Second
+		config.Config.DBMaxOpenConns = 7
+
+		require.NoError(t, InitDB())
+		sqlDB, err := DB.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+
+		assert.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+	})
+
+	t.Run("memory is migrated", func(t *testing.T) {
+		config.Config.DBDriver = config.DBDriverMemory
+		config.Config.DBConnectTimeout = time.Second
+
+		require.NoError(t, InitDB())
+		sqlDB, err := DB.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { sqlDB.Close() })
+
+		assert.NoError(t, NewMigrator(DB, Migrations).CheckSchema(context.Background()))
+		assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+	})
+}
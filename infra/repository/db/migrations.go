@@ -0,0 +1,670 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations is the history of the database schema. Each migration declares its own
+// snapshot of the tables it touches, so that later changes to the domain models do not
+// change what an old migration does. Never edit a released migration: add a new one.
+//
+// MySQL commits every schema change as soon as it runs, so a migration that fails there
+// may be left half applied. Each step of a migration checks whether it was already done,
+// so that running the migration again finishes it.
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_quotes",
+		// the tables may already exist in databases created by AutoMigrate, in which
+		// case the missing columns and indexes are added
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&quoteV1{}, &quoteDispatcherV1{}, &quoteVolumeV1{}, &carrierV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&carrierV1{}, &quoteVolumeV1{}, &quoteDispatcherV1{}, &quoteV1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "store_money_as_cents",
+		// the amounts were stored as floating point reais; they are converted to integer
+		// centavos and every existing amount is taken to be in BRL
+		Up: func(tx *gorm.DB) error {
+			err := convertColumns(tx, "ROUND(%s * 100)", []columnConversion{
+				{from: &quoteV1{}, to: &quoteV2{}, fromColumn: "total_declared_value", toColumn: "total_declared_value_cents"},
+				{from: &quoteVolumeV1{}, to: &quoteVolumeV2{}, fromColumn: "unitary_price", toColumn: "unitary_price_cents"},
+				{from: &carrierV1{}, to: &carrierV2{}, fromColumn: "price", toColumn: "price_cents"},
+				{from: &carrierV1{}, to: &carrierV2{}, fromColumn: "cost_price", toColumn: "cost_price_cents"},
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, model := range []interface{}{&quoteV2{}, &carrierV2{}} {
+				if err := addColumns(tx, model, []string{"currency"}); err != nil {
+					return err
+				}
+				if err := tx.Model(model).Where("1 = 1").Update("currency", "BRL").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&quoteV2{}, &carrierV2{}} {
+				if err := dropColumns(tx, model, []string{"currency"}); err != nil {
+					return err
+				}
+			}
+
+			return convertColumns(tx, "%s / 100.0", []columnConversion{
+				{from: &quoteV2{}, to: &quoteV1{}, fromColumn: "total_declared_value_cents", toColumn: "total_declared_value"},
+				{from: &quoteVolumeV2{}, to: &quoteVolumeV1{}, fromColumn: "unitary_price_cents", toColumn: "unitary_price"},
+				{from: &carrierV2{}, to: &carrierV1{}, fromColumn: "price_cents", toColumn: "price"},
+				{from: &carrierV2{}, to: &carrierV1{}, fromColumn: "cost_price_cents", toColumn: "cost_price"},
+			})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "store_zipcodes_as_ceps",
+		// the zipcodes were stored as integers, which lost the leading zero of CEPs such
+		// as 01310-100; they become 8 digit strings
+		Up: func(tx *gorm.DB) error {
+			return alterZipcodeColumns(tx, &quoteV3{}, &quoteDispatcherV3{}, func(column string) string {
+				if tx.Dialector.Name() == "sqlite" {
+					return fmt.Sprintf("printf('%%08d', %s)", column)
+				}
+				return fmt.Sprintf("LPAD(%s, 8, '0')", column)
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return alterZipcodeColumns(tx, &quoteV2{}, &quoteDispatcherV1{}, nil)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_volume_attributes",
+		// the SKU is indexed so that the shipping cost can be analyzed per product
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &quoteVolumeV4{}, volumeAttributes); err != nil {
+				return err
+			}
+			return createIndexes(tx, &quoteVolumeV4{}, []string{"SKU"})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &quoteVolumeV4{}, []string{"SKU"}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &quoteVolumeV4{}, volumeAttributes)
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_request_options",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &quoteV5{}, requestOptions); err != nil {
+				return err
+			}
+			return addColumns(tx, &quoteDispatcherV5{}, []string{"TotalPriceCents"})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &quoteDispatcherV5{}, []string{"TotalPriceCents"}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &quoteV5{}, requestOptions)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create_metric_rollups",
+		// the creation time the metrics filter by is indexed too; databases that ran the
+		// former restore_indexes migration already have that index
+		Up: func(tx *gorm.DB) error {
+			for _, model := range []interface{}{&metricRollupV7{}, &rollupStateV7{}} {
+				if tx.Migrator().HasTable(model) {
+					continue
+				}
+				if err := tx.Migrator().CreateTable(model); err != nil {
+					return err
+				}
+			}
+			if err := tx.FirstOrCreate(&rollupStateV7{ID: 1}).Error; err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &quoteV7{}, []string{"CreatedAt"}); err != nil {
+				return err
+			}
+			return createIndexes(tx, &carrierV7{}, []string{"PriceCents"})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &carrierV7{}, []string{"PriceCents"}); err != nil {
+				return err
+			}
+			if err := dropIndexes(tx, &quoteV7{}, []string{"CreatedAt"}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&rollupStateV7{}, &metricRollupV7{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_rollup_digests",
+		// the rollups are rolled up again to fill in their digests
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &metricRollupV8{}, rollupDigests); err != nil {
+				return err
+			}
+			return rollUpAgain(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &metricRollupV8{}, rollupDigests)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "store_times_in_utc",
+		// SQLite stores times as text with the offset they were written in and compares
+		// them as text, so the creation times written in another offset are rewritten in
+		// UTC and the rollups, which may have put them on the wrong day, are rolled up
+		// again; MySQL stores times without an offset
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "sqlite" {
+				return nil
+			}
+
+			var last uint
+			for {
+				var quotes []quoteCreatedAtV9
+				if err := tx.Where("id > ?", last).Order("id").Limit(utcBatchSize).Find(&quotes).Error; err != nil {
+					return err
+				}
+				if len(quotes) == 0 {
+					return rollUpAgain(tx)
+				}
+
+				for _, quote := range quotes {
+					if quote.CreatedAt.Location() == time.UTC {
+						continue
+					}
+					if err := tx.Model(&quote).Update("created_at", quote.CreatedAt.UTC()).Error; err != nil {
+						return err
+					}
+				}
+				last = quotes[len(quotes)-1].ID
+			}
+		},
+		// times in UTC are as valid in the previous versions
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	},
+}
+
+// utcBatchSize is how many quotes the store_times_in_utc migration reads at a time
+const utcBatchSize = 500
+
+// volumeAttributes are the fields of quoteVolumeV4 added by the add_volume_attributes
+// migration
+var volumeAttributes = []string{"AmountVolumes", "SKU", "Tag", "Description", "Manufacturer", "Consolidate", "Overlaid", "Rotate"}
+
+// requestOptions are the fields of quoteV5 added by the add_request_options migration
+var requestOptions = []string{
+	"RecipientRegisteredNumber", "RecipientStateInscription", "Channel", "Identification", "Filter", "Limit", "Reverse", "Returns",
+}
+
+// rollupDigests are the fields of metricRollupV8 added by the add_rollup_digests migration
+var rollupDigests = []string{"PriceDigest", "DeadlineDigest"}
+
+// rollUpAgain moves the watermark of the rollups back to the first rollup, so that the
+// compaction rolls every day up again, one day at a time. The rollups are kept until
+// then, as the days from the watermark on are not read from them.
+func rollUpAgain(tx *gorm.DB) error {
+	var first []metricRollupV8
+	if err := tx.Select("day").Order("day").Limit(1).Find(&first).Error; err != nil {
+		return err
+	}
+	if len(first) == 0 {
+		return nil
+	}
+	return tx.Model(&rollupStateV7{}).Where("id = ?", 1).Update("rolled_up_until", first[0].Day).Error
+}
+
+// addColumns adds the columns of the given fields of the snapshot model that do not exist yet
+func addColumns(tx *gorm.DB, model interface{}, fields []string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns drops the columns of the given fields of the snapshot model that still exist
+func dropColumns(tx *gorm.DB, model interface{}, fields []string) error {
+	return keepIndexes(tx, model, func() error {
+		for _, field := range fields {
+			if !tx.Migrator().HasColumn(model, field) {
+				continue
+			}
+			if err := tx.Migrator().DropColumn(model, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// createIndexes creates the indexes of the given fields of the snapshot model that do not
+// exist yet
+func createIndexes(tx *gorm.DB, model interface{}, fields []string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasIndex(model, field) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropIndexes drops the indexes of the given fields of the snapshot model that still exist
+func dropIndexes(tx *gorm.DB, model interface{}, fields []string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasIndex(model, field) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepIndexes runs change, creating again the indexes of the table of model it dropped.
+// SQLite rebuilds a table to drop or alter a column, which drops the indexes of the
+// table; other databases keep them.
+func keepIndexes(tx *gorm.DB, model interface{}, change func() error) error {
+	if tx.Dialector.Name() != "sqlite" {
+		return change()
+	}
+
+	statement := &gorm.Statement{DB: tx}
+	if err := statement.Parse(model); err != nil {
+		return err
+	}
+	var indexes []struct {
+		Name string
+		SQL  string
+	}
+	err := tx.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", statement.Table).
+		Scan(&indexes).Error
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if tx.Migrator().HasIndex(model, index.Name) {
+			continue
+		}
+		if err := tx.Exec(index.SQL).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alterZipcodeColumns changes the type of the zipcode columns to the one declared by
+// the snapshots quote and dispatcher, then rewrites every zipcode with fill when it is
+// set
+func alterZipcodeColumns(tx *gorm.DB, quote, dispatcher interface{}, fill func(column string) string) error {
+	columns := []struct {
+		model  interface{}
+		column string
+	}{
+		{model: quote, column: "recipient_zipcode"},
+		{model: dispatcher, column: "zipcode"},
+	}
+
+	for _, c := range columns {
+		err := keepIndexes(tx, c.model, func() error {
+			return tx.Migrator().AlterColumn(c.model, c.column)
+		})
+		if err != nil {
+			return err
+		}
+		if fill != nil {
+			query := tx.Model(c.model).Where(fmt.Sprintf("%s IS NOT NULL", c.column))
+			if err := query.Update(c.column, gorm.Expr(fill(c.column))).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnConversion replaces fromColumn, declared by the snapshot from, with toColumn,
+// declared by the snapshot to
+type columnConversion struct {
+	from       interface{}
+	to         interface{}
+	fromColumn string
+	toColumn   string
+}
+
+// convertColumns adds the new column of each conversion, fills it with expression, in
+// which %s stands for the old column, and drops the old column. A conversion whose old
+// column was already dropped is done.
+func convertColumns(tx *gorm.DB, expression string, conversions []columnConversion) error {
+	for _, conversion := range conversions {
+		if !tx.Migrator().HasColumn(conversion.from, conversion.fromColumn) {
+			continue
+		}
+		if err := addColumns(tx, conversion.to, []string{conversion.toColumn}); err != nil {
+			return err
+		}
+
+		value := gorm.Expr(fmt.Sprintf(expression, conversion.fromColumn))
+		if err := tx.Model(conversion.to).Where("1 = 1").Update(conversion.toColumn, value).Error; err != nil {
+			return err
+		}
+
+		if err := dropColumns(tx, conversion.from, []string{conversion.fromColumn}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type quoteV1 struct {
+	ID                      uint   `gorm:"primaryKey"`
+	ShipperRegisteredNumber string `gorm:"index"`
+	ShipperPlatformCode     string
+	RecipientType           int
+	RecipientCountry        string
+	RecipientZipcode        int `gorm:"index"`
+	SimulationType          string
+	TotalWeight             float64
+	TotalDeclaredValue      float64
+	Dispatchers             []quoteDispatcherV1 `gorm:"foreignKey:QuoteID"`
+	Carrier                 []carrierV1         `gorm:"foreignKey:QuoteID"`
+	CreatedAt               time.Time
+}
+
+func (quoteV1) TableName() string { return "quotes" }
+
+type quoteDispatcherV1 struct {
+	ID               uint `gorm:"primaryKey"`
+	QuoteID          uint `gorm:"index"`
+	RegisteredNumber string
+	Zipcode          int             `gorm:"index"`
+	Volumes          []quoteVolumeV1 `gorm:"foreignKey:QuoteDispatcherID"`
+}
+
+func (quoteDispatcherV1) TableName() string { return "quote_dispatchers" }
+
+type quoteVolumeV1 struct {
+	ID                uint `gorm:"primaryKey"`
+	QuoteDispatcherID uint `gorm:"index"`
+	Amount            int
+	Category          string
+	Height            float64
+	Width             float64
+	Length            float64
+	UnitaryPrice      float64
+	UnitaryWeight     float64
+}
+
+func (quoteVolumeV1) TableName() string { return "quote_volumes" }
+
+type carrierV1 struct {
+	ID                      uint `gorm:"primaryKey"`
+	QuoteID                 uint `gorm:"index"`
+	Name                    string
+	Service                 string
+	Deadline                int
+	Price                   float64
+	Provider                string
+	CarrierRegisteredNumber string
+	CarrierReference        int
+	DispatcherID            string
+	OfferID                 int
+	CostPrice               float64
+	CubicWeight             float64
+	ExpiresAt               *time.Time
+	EstimatedDeliveryDate   *time.Time
+}
+
+func (carrierV1) TableName() string { return "carriers" }
+
+type quoteV2 struct {
+	ID                      uint   `gorm:"primaryKey"`
+	ShipperRegisteredNumber string `gorm:"index"`
+	ShipperPlatformCode     string
+	RecipientType           int
+	RecipientCountry        string
+	RecipientZipcode        int `gorm:"index"`
+	SimulationType          string
+	TotalWeight             float64
+	TotalDeclaredValueCents int64
+	Currency                string `gorm:"size:3"`
+	CreatedAt               time.Time
+}
+
+func (quoteV2) TableName() string { return "quotes" }
+
+type quoteVolumeV2 struct {
+	ID                uint `gorm:"primaryKey"`
+	QuoteDispatcherID uint `gorm:"index"`
+	Amount            int
+	Category          string
+	Height            float64
+	Width             float64
+	Length            float64
+	UnitaryPriceCents int64
+	UnitaryWeight     float64
+}
+
+func (quoteVolumeV2) TableName() string { return "quote_volumes" }
+
+type carrierV2 struct {
+	ID                      uint `gorm:"primaryKey"`
+	QuoteID                 uint `gorm:"index"`
+	Name                    string
+	Service                 string
+	Deadline                int
+	PriceCents              int64
+	Currency                string `gorm:"size:3"`
+	Provider                string
+	CarrierRegisteredNumber string
+	CarrierReference        int
+	DispatcherID            string
+	OfferID                 int
+	CostPriceCents          int64
+	CubicWeight             float64
+	ExpiresAt               *time.Time
+	EstimatedDeliveryDate   *time.Time
+}
+
+func (carrierV2) TableName() string { return "carriers" }
+
+type quoteV3 struct {
+	ID                      uint   `gorm:"primaryKey"`
+	ShipperRegisteredNumber string `gorm:"index"`
+	ShipperPlatformCode     string
+	RecipientType           int
+	RecipientCountry        string
+	RecipientZipcode        string `gorm:"size:8;index"`
+	SimulationType          string
+	TotalWeight             float64
+	TotalDeclaredValueCents int64
+	Currency                string `gorm:"size:3"`
+	CreatedAt               time.Time
+}
+
+func (quoteV3) TableName() string { return "quotes" }
+
+type quoteDispatcherV3 struct {
+	ID               uint `gorm:"primaryKey"`
+	QuoteID          uint `gorm:"index"`
+	RegisteredNumber string
+	Zipcode          string `gorm:"size:8;index"`
+}
+
+func (quoteDispatcherV3) TableName() string { return "quote_dispatchers" }
+
+type quoteVolumeV4 struct {
+	ID                uint `gorm:"primaryKey"`
+	QuoteDispatcherID uint `gorm:"index"`
+	Amount            int
+	AmountVolumes     int
+	Category          string
+	SKU               string `gorm:"index"`
+	Tag               string
+	Description       string
+	Manufacturer      string
+	Height            float64
+	Width             float64
+	Length            float64
+	UnitaryPriceCents int64
+	UnitaryWeight     float64
+	Consolidate       bool
+	Overlaid          bool
+	Rotate            bool
+}
+
+func (quoteVolumeV4) TableName() string { return "quote_volumes" }
+
+type quoteV5 struct {
+	ID                        uint   `gorm:"primaryKey"`
+	ShipperRegisteredNumber   string `gorm:"index"`
+	ShipperPlatformCode       string
+	RecipientType             int
+	RecipientRegisteredNumber string
+	RecipientStateInscription string
+	RecipientCountry          string
+	RecipientZipcode          string `gorm:"size:8;index"`
+	SimulationType            string
+	Channel                   string
+	Identification            string
+	Filter                    int
+	Limit                     int
+	Reverse                   bool
+	Returns                   string
+	TotalWeight               float64
+	TotalDeclaredValueCents   int64
+	Currency                  string `gorm:"size:3"`
+	CreatedAt                 time.Time
+}
+
+func (quoteV5) TableName() string { return "quotes" }
+
+type quoteDispatcherV5 struct {
+	ID               uint `gorm:"primaryKey"`
+	QuoteID          uint `gorm:"index"`
+	RegisteredNumber string
+	Zipcode          string `gorm:"size:8;index"`
+	TotalPriceCents  int64
+}
+
+func (quoteDispatcherV5) TableName() string { return "quote_dispatchers" }
+
+type quoteV7 struct {
+	ID                        uint   `gorm:"primaryKey"`
+	ShipperRegisteredNumber   string `gorm:"index"`
+	ShipperPlatformCode       string
+	RecipientType             int
+	RecipientRegisteredNumber string
+	RecipientStateInscription string
+	RecipientCountry          string
+	RecipientZipcode          string `gorm:"size:8;index"`
+	SimulationType            string
+	Channel                   string
+	Identification            string
+	Filter                    int
+	Limit                     int
+	Reverse                   bool
+	Returns                   string
+	TotalWeight               float64
+	TotalDeclaredValueCents   int64
+	Currency                  string    `gorm:"size:3"`
+	CreatedAt                 time.Time `gorm:"index"`
+}
+
+func (quoteV7) TableName() string { return "quotes" }
+
+type metricRollupV7 struct {
+	Day             time.Time `gorm:"primaryKey"`
+	Scope           string    `gorm:"primaryKey;size:16"`
+	Carrier         string    `gorm:"primaryKey;size:191"`
+	Service         string    `gorm:"primaryKey;size:191"`
+	Quotes          int
+	Offers          int
+	TotalPriceCents int64
+	MinPriceCents   int64
+	MaxPriceCents   int64
+}
+
+func (metricRollupV7) TableName() string { return "metric_rollups" }
+
+type rollupStateV7 struct {
+	ID            uint `gorm:"primaryKey"`
+	RolledUpUntil *time.Time
+}
+
+func (rollupStateV7) TableName() string { return "metric_rollup_state" }
+
+type carrierV7 struct {
+	ID                      uint `gorm:"primaryKey"`
+	QuoteID                 uint `gorm:"index"`
+	Name                    string
+	Service                 string
+	Deadline                int
+	PriceCents              int64  `gorm:"index"`
+	Currency                string `gorm:"size:3"`
+	Provider                string
+	CarrierRegisteredNumber string
+	CarrierReference        int
+	DispatcherID            string
+	OfferID                 int
+	CostPriceCents          int64
+	CubicWeight             float64
+	ExpiresAt               *time.Time
+	EstimatedDeliveryDate   *time.Time
+}
+
+func (carrierV7) TableName() string { return "carriers" }
+
+type metricRollupV8 struct {
+	Day             time.Time `gorm:"primaryKey"`
+	Scope           string    `gorm:"primaryKey;size:16"`
+	Carrier         string    `gorm:"primaryKey;size:191"`
+	Service         string    `gorm:"primaryKey;size:191"`
+	Quotes          int
+	Offers          int
+	TotalPriceCents int64
+	MinPriceCents   int64
+	MaxPriceCents   int64
+	PriceDigest     string `gorm:"type:text"`
+	DeadlineDigest  string `gorm:"type:text"`
+}
+
+func (metricRollupV8) TableName() string { return "metric_rollups" }
+
+type quoteCreatedAtV9 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+func (quoteCreatedAtV9) TableName() string { return "quotes" }
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// migrationsUpTo returns the migrations up to version
+func migrationsUpTo(version uint) []Migration {
+	var migrations []Migration
+	for _, migration := range Migrations {
+		if migration.Version <= version {
+			migrations = append(migrations, migration)
+		}
+	}
+	return migrations
+}
+
+func TestMigrations_SchemaMatchesModels(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, model := range []interface{}{&domain.Quote{}, &domain.QuoteDispatcher{}, &domain.QuoteVolume{}, &domain.Carrier{}, &metricRollup{}, &rollupState{}} {
+		statement := &gorm.Statement{DB: db}
+		require.NoError(t, statement.Parse(model))
+
+		for _, field := range statement.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", statement.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrations_IndexesMatchModels(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, model := range []interface{}{&domain.Quote{}, &domain.QuoteDispatcher{}, &domain.QuoteVolume{}, &domain.Carrier{}, &metricRollup{}} {
+		statement := &gorm.Statement{DB: db}
+		require.NoError(t, statement.Parse(model))
+
+		for _, index := range statement.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, index.Name), "%s.%s", statement.Table, index.Name)
+		}
+	}
+}
+
+func TestMigrations_RunAgain(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	// a migration that failed halfway on MySQL is run again over the changes it committed
+	_, err := NewMigrator(db, Migrations).Up(ctx)
+	require.NoError(t, err)
+	for _, migration := range Migrations {
+		assert.NoError(t, migration.Up(db), "%d_%s", migration.Version, migration.Name)
+	}
+
+	for i := len(Migrations) - 1; i >= 0; i-- {
+		migration := Migrations[i]
+		_, err := NewMigrator(db, Migrations).Down(ctx, 1)
+		require.NoError(t, err)
+		assert.NoError(t, migration.Down(db), "%d_%s", migration.Version, migration.Name)
+	}
+}
+
+func TestMigrations_StoreMoneyAsCents(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, migrationsUpTo(1)).Up(ctx)
+	require.NoError(t, err)
+	quote := quoteV1{
+		TotalDeclaredValue: 34.00000000001,
+		Dispatchers:        []quoteDispatcherV1{{Volumes: []quoteVolumeV1{{Amount: 2, UnitaryPrice: 17.005}}}},
+		Carrier:            []carrierV1{{Name: "Correios", Price: 22.14, CostPrice: 20.999999}},
+	}
+	require.NoError(t, db.Create(&quote).Error)
+
+	migrator := NewMigrator(db, migrationsUpTo(2))
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var quotes []quoteV2
+	require.NoError(t, db.Find(&quotes).Error)
+	require.Len(t, quotes, 1)
+	assert.Equal(t, int64(3400), quotes[0].TotalDeclaredValueCents)
+	assert.Equal(t, "BRL", quotes[0].Currency)
+
+	var volume quoteVolumeV2
+	require.NoError(t, db.First(&volume).Error)
+	assert.Equal(t, int64(1701), volume.UnitaryPriceCents)
+
+	var carrier carrierV2
+	require.NoError(t, db.First(&carrier).Error)
+	assert.Equal(t, int64(2214), carrier.PriceCents)
+	assert.Equal(t, int64(2100), carrier.CostPriceCents)
+	assert.Equal(t, "BRL", carrier.Currency)
+
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+
+	var reverted carrierV1
+	require.NoError(t, db.First(&reverted).Error)
+	assert.Equal(t, 22.14, reverted.Price)
+	assert.Equal(t, 21.0, reverted.CostPrice)
+	assert.False(t, db.Migrator().HasColumn(&carrierV2{}, "currency"))
+}
+
+func TestMigrations_StoreZipcodesAsCEPs(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, migrationsUpTo(2)).Up(ctx)
+	require.NoError(t, err)
+	quote := quoteV2{RecipientZipcode: 1310100}
+	require.NoError(t, db.Create(&quote).Error)
+	require.NoError(t, db.Create(&quoteDispatcherV1{QuoteID: quote.ID, Zipcode: 29161376}).Error)
+
+	migrator := NewMigrator(db, migrationsUpTo(3))
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var migrated quoteV3
+	require.NoError(t, db.First(&migrated).Error)
+	assert.Equal(t, "01310100", migrated.RecipientZipcode)
+	var dispatcher quoteDispatcherV3
+	require.NoError(t, db.First(&dispatcher).Error)
+	assert.Equal(t, "29161376", dispatcher.Zipcode)
+	assert.True(t, db.Migrator().HasIndex(&quoteV3{}, "RecipientZipcode"))
+	assert.True(t, db.Migrator().HasIndex(&quoteDispatcherV3{}, "Zipcode"))
+
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+
+	var reverted quoteV2
+	require.NoError(t, db.First(&reverted).Error)
+	assert.Equal(t, 1310100, reverted.RecipientZipcode)
+	assert.True(t, db.Migrator().HasIndex(&quoteV2{}, "RecipientZipcode"))
+}
+
+func TestMigrations_AddVolumeAttributes(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, migrationsUpTo(3)).Up(ctx)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&quoteVolumeV2{Amount: 2, Category: "7"}).Error)
+
+	migrator := NewMigrator(db, migrationsUpTo(4))
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var volume quoteVolumeV4
+	require.NoError(t, db.First(&volume).Error)
+	assert.Equal(t, 2, volume.Amount)
+	assert.Equal(t, "", volume.SKU)
+	assert.False(t, volume.Consolidate)
+	assert.True(t, db.Migrator().HasIndex(&quoteVolumeV4{}, "SKU"))
+
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+
+	assert.False(t, db.Migrator().HasColumn(&quoteVolumeV4{}, "sku"))
+	assert.False(t, db.Migrator().HasColumn(&quoteVolumeV4{}, "consolidate"))
+	var reverted quoteVolumeV2
+	require.NoError(t, db.First(&reverted).Error)
+	assert.Equal(t, 2, reverted.Amount)
+}
+
+func TestMigrations_AddRequestOptions(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, migrationsUpTo(4)).Up(ctx)
+	require.NoError(t, err)
+	quote := quoteV3{RecipientCountry: "BRA", RecipientZipcode: "01310100"}
+	require.NoError(t, db.Create(&quote).Error)
+	require.NoError(t, db.Create(&quoteDispatcherV3{QuoteID: quote.ID, Zipcode: "29161376"}).Error)
+
+	migrator := NewMigrator(db, migrationsUpTo(5))
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var migrated quoteV5
+	require.NoError(t, db.First(&migrated).Error)
+	assert.Equal(t, "01310100", migrated.RecipientZipcode)
+	assert.Equal(t, 0, migrated.Limit)
+	assert.False(t, migrated.Reverse)
+	var dispatcher quoteDispatcherV5
+	require.NoError(t, db.First(&dispatcher).Error)
+	assert.Equal(t, int64(0), dispatcher.TotalPriceCents)
+
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+
+	assert.False(t, db.Migrator().HasColumn(&quoteV5{}, "channel"))
+	assert.False(t, db.Migrator().HasColumn(&quoteV5{}, "limit"))
+	assert.False(t, db.Migrator().HasColumn(&quoteDispatcherV5{}, "total_price_cents"))
+	var reverted quoteV3
+	require.NoError(t, db.First(&reverted).Error)
+	assert.Equal(t, "BRA", reverted.RecipientCountry)
+}
+
+func TestMigrations_KeepIndexes(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	migrator := NewMigrator(db, migrationsUpTo(5))
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+
+	indexes := []struct {
+		model interface{}
+		field string
+	}{
+		{model: &quoteV5{}, field: "ShipperRegisteredNumber"},
+		{model: &quoteV5{}, field: "RecipientZipcode"},
+		{model: &quoteDispatcherV5{}, field: "QuoteID"},
+		{model: &quoteDispatcherV5{}, field: "Zipcode"},
+		{model: &quoteVolumeV4{}, field: "QuoteDispatcherID"},
+		{model: &quoteVolumeV4{}, field: "SKU"},
+		{model: &carrierV2{}, field: "QuoteID"},
+	}
+	for _, index := range indexes {
+		assert.True(t, db.Migrator().HasIndex(index.model, index.field), index.field)
+	}
+
+	_, err = migrator.Down(ctx, 4)
+	require.NoError(t, err)
+
+	for _, index := range indexes[:len(indexes)-2] {
+		assert.True(t, db.Migrator().HasIndex(index.model, index.field), index.field)
+	}
+	assert.True(t, db.Migrator().HasIndex(&carrierV1{}, "QuoteID"))
+}
+
+func TestMigrations_CreateMetricRollups(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	migrator := NewMigrator(db, migrationsUpTo(7))
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+
+	var state rollupState
+	require.NoError(t, db.First(&state, rollupStateID).Error)
+	assert.Nil(t, state.RolledUpUntil)
+	assert.True(t, db.Migrator().HasIndex(&carrierV7{}, "PriceCents"))
+	assert.True(t, db.Migrator().HasIndex(&quoteV7{}, "CreatedAt"))
+
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+
+	assert.False(t, db.Migrator().HasTable(&metricRollupV7{}))
+	assert.False(t, db.Migrator().HasTable(&rollupStateV7{}))
+	assert.False(t, db.Migrator().HasIndex(&carrierV7{}, "PriceCents"))
+	assert.False(t, db.Migrator().HasIndex(&quoteV7{}, "CreatedAt"))
+}
+
+func TestMigrations_AddRollupDigests(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, migrationsUpTo(7)).Up(ctx)
+	require.NoError(t, err)
+	day := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rolledUpUntil := day.AddDate(0, 0, 2)
+	for _, d := range []time.Time{day, day.AddDate(0, 0, 1)} {
+		require.NoError(t, db.Create(&metricRollupV7{Day: d, Scope: rollupScopeAll, Quotes: 1, Offers: 1}).Error)
+	}
+	require.NoError(t, db.Save(&rollupStateV7{ID: 1, RolledUpUntil: &rolledUpUntil}).Error)
+
+	migrator := NewMigrator(db, migrationsUpTo(8))
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	for _, field := range rollupDigests {
+		assert.True(t, db.Migrator().HasColumn(&metricRollupV8{}, field), field)
+	}
+	var count int64
+	require.NoError(t, db.Model(&metricRollupV8{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+	var state rollupStateV7
+	require.NoError(t, db.First(&state, 1).Error)
+	require.NotNil(t, state.RolledUpUntil)
+	assert.True(t, day.Equal(*state.RolledUpUntil))
+
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+
+	for _, field := range rollupDigests {
+		assert.False(t, db.Migrator().HasColumn(&metricRollupV8{}, field), field)
+	}
+}
+
+func TestMigrations_StoreTimesInUTC(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, migrationsUpTo(8)).Up(ctx)
+	require.NoError(t, err)
+	local := time.Date(2024, 6, 1, 22, 0, 0, 0, time.FixedZone("-03", -3*60*60))
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	rolledUpUntil := day.AddDate(0, 1, 5)
+	require.NoError(t, db.Create(&quoteCreatedAtV9{CreatedAt: local}).Error)
+	require.NoError(t, db.Create(&quoteCreatedAtV9{CreatedAt: local.UTC()}).Error)
+	require.NoError(t, db.Create(&metricRollupV8{Day: day, Scope: rollupScopeAll, Quotes: 1, Offers: 1}).Error)
+	require.NoError(t, db.Save(&rollupStateV7{ID: 1, RolledUpUntil: &rolledUpUntil}).Error)
+
+	_, err = NewMigrator(db, migrationsUpTo(9)).Up(ctx)
+	require.NoError(t, err)
+
+	var stored []string
+	require.NoError(t, db.Raw("SELECT created_at FROM quotes ORDER BY id").Scan(&stored).Error)
+	assert.Equal(t, []string{"2024-06-02T01:00:00Z", "2024-06-02T01:00:00Z"}, stored)
+	var state rollupStateV7
+	require.NoError(t, db.First(&state, 1).Error)
+	require.NotNil(t, state.RolledUpUntil)
+	assert.True(t, day.Equal(*state.RolledUpUntil))
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+var testMigrations = []Migration{
+	{
+		Version: 2,
+		Name:    "add_widget_color",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE widgets ADD COLUMN color TEXT").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE widgets DROP COLUMN color").Error
+		},
+	},
+	{
+		Version: 1,
+		Name:    "create_widgets",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&widget{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&widget{})
+		},
+	},
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+	migrator := NewMigrator(db, testMigrations)
+
+	assert.ErrorIs(t, migrator.CheckSchema(ctx), ErrSchemaBehind)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.Equal(t, uint(1), applied[0].Version, "migrations are applied in version order")
+	assert.True(t, db.Migrator().HasColumn(&widget{}, "color"))
+	assert.NoError(t, migrator.CheckSchema(ctx))
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, reverted, 1)
+	assert.Equal(t, uint(2), reverted[0].Version)
+	assert.False(t, db.Migrator().HasColumn(&widget{}, "color"))
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), statuses[0].Version)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Equal(t, uint(2), statuses[1].Version)
+	assert.Nil(t, statuses[1].AppliedAt)
+	assert.ErrorIs(t, migrator.CheckSchema(ctx), ErrSchemaBehind)
+}
+
+func TestMigrator_FailedMigrationIsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	migrator := NewMigrator(newEmptyTestDB(t), append(testMigrations[1:], Migration{
+		Version: 2,
+		Name:    "broken",
+		Up: func(tx *gorm.DB) error {
+			return errors.New("boom")
+		},
+	}))
+
+	applied, err := migrator.Up(ctx)
+	assert.EqualError(t, err, "migration 2_broken: boom")
+	assert.Len(t, applied, 1)
+
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "broken", pending[0].Name)
+}
+
+func TestMigrations_UpAndDown(t *testing.T) {
+	ctx := context.Background()
+	db := newEmptyTestDB(t)
+	migrator := NewMigrator(db, Migrations)
+
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("quotes"))
+
+	_, err = migrator.Down(ctx, len(Migrations))
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("quotes"))
+	assert.False(t, db.Migrator().HasTable("carriers"))
+}
@@ -0,0 +1,482 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/belmadge/freteRapido/utils"
+	"gorm.io/gorm"
+)
+
+var _ repository.QuoteRepository = (*QuoteRepository)(nil)
+
+// sortColumns maps the sort fields to the columns of the quotes table
+var sortColumns = map[domain.QuoteSortField]string{
+	domain.SortByCreatedAt:          "created_at",
+	domain.SortByTotalWeight:        "total_weight",
+	domain.SortByTotalDeclaredValue: "total_declared_value_cents",
+}
+
+// QuoteRepository is the GORM implementation of repository.QuoteRepository
+type QuoteRepository struct {
+	db *gorm.DB
+	// exactStatsLimit is the largest number of offers whose spread is calculated exactly
+	exactStatsLimit int
+	now             func() time.Time
+}
+
+// NewQuoteRepository creates a QuoteRepository that stores the quotes in db
+func NewQuoteRepository(db *gorm.DB) *QuoteRepository {
+	return &QuoteRepository{db: db, exactStatsLimit: utils.ExactStatsLimit, now: time.Now}
+}
+
+// Save stores the quote with its dispatchers, volumes and carriers, with its creation time
+// in UTC. A quote created on a day already rolled up has the day rolled up again by the
+// next compaction.
+func (r *QuoteRepository) Save(ctx context.Context, quote *domain.Quote) error {
+	quote.CreatedAt = quote.CreatedAt.UTC()
+	now := r.now()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(quote).Error; err != nil {
+			return err
+		}
+		return invalidateRollups(tx, quote.CreatedAt, now)
+	})
+}
+
+// GetByID loads a quote with its request and carriers
+func (r *QuoteRepository) GetByID(ctx context.Context, id uint) (*domain.Quote, error) {
+	var quote domain.Quote
+	result := preloadQuote(r.db.WithContext(ctx)).First(&quote, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrQuoteNotFound
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &quote, nil
+}
+
+// List loads a page of the quotes matching filter, returning the cursor of the
+// next page or an empty cursor when this is the last one
+func (r *QuoteRepository) List(ctx context.Context, filter domain.QuoteFilter) ([]domain.Quote, string, error) {
+	query := preloadQuote(r.db.WithContext(ctx).Model(&domain.Quote{}))
+	query = applyQuoteFilter(query, filter)
+
+	sortColumn := sortColumns[filter.Sort.Field]
+	direction, comparison := "ASC", ">"
+	if filter.Sort.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.After != nil {
+		value, err := filter.After.SortValue(filter.Sort)
+		if err != nil {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		if createdAt, ok := value.(time.Time); ok {
+			value = createdAt.UTC()
+		}
+		query = query.Where(
+			fmt.Sprintf("(quotes.%[1]s %[2]s ?) OR (quotes.%[1]s = ? AND quotes.id %[2]s ?)", sortColumn, comparison),
+			value, value, filter.After.ID,
+		)
+	}
+
+	var quotes []domain.Quote
+	result := query.
+		Order(fmt.Sprintf("quotes.%s %s, quotes.id %s", sortColumn, direction, direction)).
+		Limit(filter.Limit + 1).
+		Find(&quotes)
+	if result.Error != nil {
+		return nil, "", result.Error
+	}
+
+	var nextCursor string
+	if len(quotes) > filter.Limit {
+		quotes = quotes[:filter.Limit]
+		nextCursor = domain.NewQuoteCursor(quotes[len(quotes)-1], filter.Sort).Encode()
+	}
+
+	return quotes, nextCursor, nil
+}
+
+// Aggregate calculates the metrics of the carrier offers matching filter with GROUP BY
+// queries, so that the quotes are never loaded. Whole days already rolled up are read
+// from the daily rollups and only the partial edges of the window from the raw rows. The
+// averages are calculated from the totals, rounded half to even like
+// utils.CalculateMetrics does, and the spread of the prices and deadlines is exact up to
+// the same number of offers.
+func (r *QuoteRepository) Aggregate(ctx context.Context, filter domain.MetricsFilter) (domain.Metrics, error) {
+	db := r.db.WithContext(ctx)
+	metrics := domain.Metrics{
+		Currency: domain.CurrencyBRL,
+		Carriers: map[string]domain.CarrierMetrics{},
+	}
+
+	days, edges, err := splitMetricsWindow(db, filter)
+	if err != nil {
+		return domain.Metrics{}, err
+	}
+
+	var quotes int
+	totals := map[carrierService]carrierTotals{}
+	if days != nil {
+		rolledUp, rolledUpTotals, err := rollupTotals(db, *days, filter)
+		if err != nil {
+			return domain.Metrics{}, err
+		}
+		quotes += rolledUp
+		mergeCarrierTotals(totals, rolledUpTotals)
+	}
+	for _, edge := range edges {
+		raw, rawTotals, err := rawTotals(db, edge)
+		if err != nil {
+			return domain.Metrics{}, err
+		}
+		quotes += raw
+		mergeCarrierTotals(totals, rawTotals)
+	}
+
+	metrics.Window = filter.Window(quotes)
+	if quotes == 0 {
+		return metrics, nil
+	}
+
+	var offers int
+	var minPrice, maxPrice domain.Money
+	first := true
+	for _, total := range totals {
+		carrier := metrics.Carriers[total.Name]
+		carrier.Count += total.Count
+		carrier.TotalPrice += total.TotalPrice
+		if carrier.Services == nil {
+			carrier.Services = map[string]domain.ServiceMetrics{}
+		}
+		carrier.Services[total.Service] = domain.ServiceMetrics{
+			Count:        total.Count,
+			TotalPrice:   total.TotalPrice,
+			AveragePrice: total.TotalPrice.Div(total.Count),
+		}
+		metrics.Carriers[total.Name] = carrier
+
+		offers += total.Count
+		if first || total.MinPrice < minPrice {
+			minPrice = total.MinPrice
+		}
+		if first || total.MaxPrice > maxPrice {
+			maxPrice = total.MaxPrice
+		}
+		first = false
+	}
+	for name, carrier := range metrics.Carriers {
+		carrier.AveragePrice = carrier.TotalPrice.Div(carrier.Count)
+		metrics.Carriers[name] = carrier
+	}
+
+	stats, err := r.offerStats(db, filter, days, edges, offers)
+	if err != nil {
+		return domain.Metrics{}, err
+	}
+	stats.Apply(&metrics)
+	metrics.CostVsSpeed = utils.CompareCostVsSpeed(metrics.Carriers)
+
+	var minDeadline, maxDeadline int
+	first = true
+	for _, carrier := range metrics.Carriers {
+		if first || carrier.DeadlineStats.Min < minDeadline {
+			minDeadline = carrier.DeadlineStats.Min
+		}
+		if first || carrier.DeadlineStats.Max > maxDeadline {
+			maxDeadline = carrier.DeadlineStats.Max
+		}
+		first = false
+	}
+
+	// With the extremes known, each quote is the first offer that has it
+	offersOf := func() *gorm.DB {
+		if days != nil {
+			return windowOffers(db, filter)
+		}
+		return matchedOffers(db, filter)
+	}
+	if metrics.CheapestQuote, err = firstOffer(offersOf().Where("carriers.price_cents = ?", minPrice)); err != nil {
+		return domain.Metrics{}, err
+	}
+	if metrics.MostExpensiveQuote, err = firstOffer(offersOf().Where("carriers.price_cents = ?", maxPrice)); err != nil {
+		return domain.Metrics{}, err
+	}
+	if metrics.FastestQuote, err = firstOffer(offersOf().Where("carriers.deadline = ?", minDeadline), "carriers.price_cents"); err != nil {
+		return domain.Metrics{}, err
+	}
+	if metrics.SlowestQuote, err = firstOffer(offersOf().Where("carriers.deadline = ?", maxDeadline), "carriers.price_cents"); err != nil {
+		return domain.Metrics{}, err
+	}
+
+	return metrics, nil
+}
+
+// carrierTotals is a row of the metrics grouped by carrier and service
+type carrierTotals struct {
+	Name       string
+	Service    string
+	Count      int
+	TotalPrice domain.Money
+	MinPrice   domain.Money
+	MaxPrice   domain.Money
+}
+
+// carrierService identifies the offers of a service of a carrier
+type carrierService struct {
+	carrier string
+	service string
+}
+
+// rawTotals counts the quotes matching filter and totals their matching offers by
+// carrier and service from the raw rows
+func rawTotals(db *gorm.DB, filter domain.MetricsFilter) (int, []carrierTotals, error) {
+	var quotes int64
+	if err := db.Table("(?) AS matched_quotes", matchedQuotes(db, filter)).Count(&quotes).Error; err != nil {
+		return 0, nil, err
+	}
+	if quotes == 0 {
+		return 0, nil, nil
+	}
+
+	var totals []carrierTotals
+	err := matchedOffers(db, filter).
+		Select("carriers.name AS name, carriers.service AS service, COUNT(*) AS count, " +
+			"SUM(carriers.price_cents) AS total_price, MIN(carriers.price_cents) AS min_price, " +
+			"MAX(carriers.price_cents) AS max_price").
+		Group("carriers.name").
+		Group("carriers.service").
+		Scan(&totals).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(quotes), totals, nil
+}
+
+// mergeCarrierTotals adds the totals of rows to the totals by carrier and service
+func mergeCarrierTotals(totals map[carrierService]carrierTotals, rows []carrierTotals) {
+	for _, row := range rows {
+		key := carrierService{carrier: row.Name, service: row.Service}
+		total, ok := totals[key]
+		if !ok {
+			totals[key] = row
+			continue
+		}
+		total.Count += row.Count
+		total.TotalPrice += row.TotalPrice
+		if row.MinPrice < total.MinPrice {
+			total.MinPrice = row.MinPrice
+		}
+		if row.MaxPrice > total.MaxPrice {
+			total.MaxPrice = row.MaxPrice
+		}
+		totals[key] = total
+	}
+}
+
+// offerStats calculates the spread of the prices and deadlines of the given number of
+// offers matching filter. Up to exactStatsLimit offers every one of them is read. Past
+// it the rolled up days are merged from the digests of the rollups, and the offers of
+// the rest of the window are only read as the number of offers with each price and
+// each deadline.
+func (r *QuoteRepository) offerStats(db *gorm.DB, filter domain.MetricsFilter, days *rollupDays, edges []domain.MetricsFilter, offers int) (*utils.OfferStats, error) {
+	exact := offers <= r.exactStatsLimit
+	stats := utils.NewOfferStats(exact)
+	if exact {
+		return stats, addOffers(stats, matchedOffers(db, filter))
+	}
+	if days == nil {
+		return stats, addOfferCounts(stats, db, filter)
+	}
+
+	if err := mergeRollupDigests(db, *days, filter, stats); err != nil {
+		return nil, err
+	}
+	for _, edge := range edges {
+		if err := addOfferCounts(stats, db, edge); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// offerCount is the number of offers of a carrier and service with the same value
+type offerCount struct {
+	Name    string
+	Service string
+	Value   int64
+	Count   int
+}
+
+// addOfferCounts adds the prices and deadlines of the offers matching filter to stats,
+// grouping the offers with the same value in SQL so that only the distinct values of
+// each carrier and service are read
+func addOfferCounts(stats *utils.OfferStats, db *gorm.DB, filter domain.MetricsFilter) error {
+	columns := []struct {
+		name string
+		add  func(count offerCount)
+	}{
+		{"carriers.price_cents", func(count offerCount) {
+			stats.AddPrices(count.Name, count.Service, domain.Money(count.Value), count.Count)
+		}},
+		{"carriers.deadline", func(count offerCount) {
+			stats.AddDeadlines(count.Name, count.Service, int(count.Value), count.Count)
+		}},
+	}
+
+	for _, column := range columns {
+		var counts []offerCount
+		err := matchedOffers(db, filter).
+			Select("carriers.name AS name, carriers.service AS service, " + column.name + " AS value, COUNT(*) AS count").
+			Group("carriers.name").
+			Group("carriers.service").
+			Group(column.name).
+			Scan(&counts).Error
+		if err != nil {
+			return err
+		}
+
+		for _, count := range counts {
+			column.add(count)
+		}
+	}
+	return nil
+}
+
+// addOffers streams the price and deadline of the offers of query into stats
+func addOffers(stats *utils.OfferStats, query *gorm.DB) error {
+	rows, err := query.Select("carriers.name, carriers.service, carriers.price_cents, carriers.deadline").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var carrier domain.Carrier
+		if err := rows.Scan(&carrier.Name, &carrier.Service, &carrier.Price, &carrier.Deadline); err != nil {
+			return err
+		}
+		stats.Add(carrier)
+	}
+	return rows.Err()
+}
+
+// matchedQuotes selects the ID and creation time of the quotes matching filter, newest
+// first. MySQL does not take LIMIT in IN subqueries, so it is joined as a derived table.
+func matchedQuotes(db *gorm.DB, filter domain.MetricsFilter) *gorm.DB {
+	query := applyMetricsFilter(db.Model(&domain.Quote{}), filter).
+		Select("quotes.id, quotes.created_at").
+		Order("quotes.created_at DESC").
+		Order("quotes.id DESC")
+	if filter.LastQuotes > 0 {
+		query = query.Limit(filter.LastQuotes)
+	}
+	return query
+}
+
+// matchedOffers selects the carrier offers of the quotes matching filter that match
+// its carrier, service and price band
+func matchedOffers(db *gorm.DB, filter domain.MetricsFilter) *gorm.DB {
+	query := db.Model(&domain.Carrier{}).
+		Joins("JOIN (?) AS matched_quotes ON matched_quotes.id = carriers.quote_id", matchedQuotes(db, filter))
+	query, _ = whereCarrier(query, filter.Carrier, filter.Service, filter.MinPrice, filter.MaxPrice)
+	return query
+}
+
+// firstOffer returns the first offer of query in the given order. Offers that tie are
+// taken from the newest quote, in the order they were offered.
+func firstOffer(query *gorm.DB, orders ...string) (*domain.Carrier, error) {
+	query = query.Select("carriers.*")
+	for _, order := range orders {
+		query = query.Order(order)
+	}
+
+	var carrier domain.Carrier
+	err := query.
+		Order("matched_quotes.created_at DESC").
+		Order("matched_quotes.id DESC").
+		Order("carriers.id").
+		Take(&carrier).Error
+	if err != nil {
+		return nil, err
+	}
+	return &carrier, nil
+}
+
+func preloadQuote(query *gorm.DB) *gorm.DB {
+	return query.Preload("Carrier").Preload("Dispatchers.Volumes")
+}
+
+func applyQuoteFilter(query *gorm.DB, filter domain.QuoteFilter) *gorm.DB {
+	if filter.From != nil {
+		query = query.Where("quotes.created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("quotes.created_at < ?", filter.To.UTC())
+	}
+	if filter.RecipientZipcode != "" {
+		query = query.Where("quotes.recipient_zipcode = ?", filter.RecipientZipcode)
+	}
+
+	carriers := query.Session(&gorm.Session{NewDB: true}).Model(&domain.Carrier{}).Select("1").Where("carriers.quote_id = quotes.id")
+	if carriers, filterCarriers := whereCarrier(carriers, filter.Carrier, filter.Service, filter.MinPrice, filter.MaxPrice); filterCarriers {
+		query = query.Where("EXISTS (?)", carriers)
+	}
+
+	return query
+}
+
+// applyMetricsFilter narrows query to the quotes within the window and zipcode prefixes
+// of filter that have at least one matching carrier offer
+func applyMetricsFilter(query *gorm.DB, filter domain.MetricsFilter) *gorm.DB {
+	if filter.From != nil {
+		query = query.Where("quotes.created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("quotes.created_at < ?", filter.To.UTC())
+	}
+	if filter.DestinationZipcodePrefix != "" {
+		query = query.Where("quotes.recipient_zipcode LIKE ?", filter.DestinationZipcodePrefix+"%")
+	}
+	if filter.OriginZipcodePrefix != "" {
+		dispatchers := query.Session(&gorm.Session{NewDB: true}).Model(&domain.QuoteDispatcher{}).Select("1").
+			Where("quote_dispatchers.quote_id = quotes.id AND quote_dispatchers.zipcode LIKE ?", filter.OriginZipcodePrefix+"%")
+		query = query.Where("EXISTS (?)", dispatchers)
+	}
+
+	carriers := query.Session(&gorm.Session{NewDB: true}).Model(&domain.Carrier{}).Select("1").Where("carriers.quote_id = quotes.id")
+	carriers, _ = whereCarrier(carriers, filter.Carrier, filter.Service, filter.MinPrice, filter.MaxPrice)
+	return query.Where("EXISTS (?)", carriers)
+}
+
+// whereCarrier narrows a query over the carriers table to the offers of the given
+// carrier and service within the price range, reporting whether any condition was added
+func whereCarrier(query *gorm.DB, name, service string, minPrice, maxPrice *domain.Money) (*gorm.DB, bool) {
+	filtered := false
+	if name != "" {
+		query = query.Where("carriers.name = ?", name)
+		filtered = true
+	}
+	if service != "" {
+		query = query.Where("carriers.service = ?", service)
+		filtered = true
+	}
+	if minPrice != nil {
+		query = query.Where("carriers.price_cents >= ?", *minPrice)
+		filtered = true
+	}
+	if maxPrice != nil {
+		query = query.Where("carriers.price_cents <= ?", *maxPrice)
+		filtered = true
+	}
+	return query, filtered
+}
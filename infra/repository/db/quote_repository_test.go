@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/belmadge/freteRapido/infra/repository/repositorytest"
+	"github.com/belmadge/freteRapido/utils"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newEmptyTestDB opens a private in-memory SQLite database
+func newEmptyTestDB(t testing.TB) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), newGormConfig())
+	require.NoError(t, err)
+	db.Logger = logger.Discard
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return db
+}
+
+// newTestDB opens a private in-memory SQLite database with every migration applied
+func newTestDB(t testing.TB) *gorm.DB {
+	db := newEmptyTestDB(t)
+
+	_, err := NewMigrator(db, Migrations).Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+// newEmptyRepository creates a repository on an empty database for the shared repository
+// tests
+func newEmptyRepository(t *testing.T) repository.QuoteRepository {
+	return NewQuoteRepository(newTestDB(t))
+}
+
+func TestQuoteRepository_SaveAndGetByID(t *testing.T) {
+	repository := NewQuoteRepository(newTestDB(t))
+
+	input := domain.QuoteRequest{
+		Shipper:   domain.Shipper{RegisteredNumber: "25438296000158", Token: "secret", PlatformCode: "5AKVkHqCn"},
+		Recipient: domain.Recipient{Type: 1, RegisteredNumber: "04884082000135", StateInscription: "ISENTO", Country: "BRA", Zipcode: "29161376"},
+		Dispatchers: []domain.Dispatcher{
+			{
+				RegisteredNumber: "25438296000158",
+				Zipcode:          "29161376",
+				TotalPrice:       40000,
+				Volumes: []domain.Volume{
+					{Category: "7", Amount: 1, UnitaryWeight: 5, UnitaryPrice: 34900, Height: 0.2, Width: 0.2, Length: 0.2, SKU: "abc-teste-123", Rotate: true},
+				},
+			},
+		},
+		SimulationType: []int{0},
+		Channel:        "marketplace",
+		Filter:         1,
+		Limit:          3,
+		Reverse:        true,
+		Returns:        &domain.QuoteReturns{Composition: true},
+	}
+	quote := domain.NewQuote(input, []domain.Carrier{{Name: "Correios", Service: "SEDEX", Deadline: 1, Price: 2099}})
+
+	require.NoError(t, repository.Save(context.Background(), &quote))
+	assert.NotZero(t, quote.ID)
+
+	found, err := repository.GetByID(context.Background(), quote.ID)
+	require.NoError(t, err)
+
+	expectedRequest := input
+	expectedRequest.Shipper.Token = ""
+	assert.Equal(t, expectedRequest, found.Request())
+	assert.Equal(t, 5.0, found.TotalWeight)
+	assert.Len(t, found.Carrier, 1)
+	assert.Equal(t, domain.Money(2099), found.Carrier[0].Price)
+
+	_, err = repository.GetByID(context.Background(), quote.ID+1)
+	assert.ErrorIs(t, err, domain.ErrQuoteNotFound)
+}
+
+func TestQuoteRepository_List(t *testing.T) {
+	repositorytest.TestList(t, newEmptyRepository)
+}
+
+func TestQuoteRepository_List_Pagination(t *testing.T) {
+	repositorytest.TestListPagination(t, newEmptyRepository)
+}
+
+func TestQuoteRepository_Aggregate(t *testing.T) {
+	repositorytest.TestAggregate(t, newEmptyRepository)
+}
+
+func TestQuoteRepository_Aggregate_Filters(t *testing.T) {
+	repositorytest.TestAggregateFilters(t, newEmptyRepository)
+}
+
+// aggregateInMemory is the former implementation of Aggregate, which loads the matching
+// quotes with their offers and aggregates them in Go. It is kept to check the GROUP BY
+// queries against and to compare their performance.
+func aggregateInMemory(db *gorm.DB, filter domain.MetricsFilter) (domain.Metrics, error) {
+	query := applyMetricsFilter(db.Model(&domain.Quote{}), filter).Preload("Carrier", func(carriers *gorm.DB) *gorm.DB {
+		carriers, _ = whereCarrier(carriers, filter.Carrier, filter.Service, filter.MinPrice, filter.MaxPrice)
+		return carriers
+	})
+	query = query.Order("quotes.created_at desc").Order("quotes.id desc")
+	if filter.LastQuotes > 0 {
+		query = query.Limit(filter.LastQuotes)
+	}
+
+	var quotes []domain.Quote
+	if err := query.Find(&quotes).Error; err != nil {
+		return domain.Metrics{}, err
+	}
+
+	metrics := utils.CalculateMetrics(quotes)
+	metrics.Window = filter.Window(len(quotes))
+	return metrics, nil
+}
+
+// seedQuotes stores n quotes with three offers each from a fixed set of carriers, with
+// prices and deadlines repeating often enough to exercise ties
+func seedQuotes(t testing.TB, repository *QuoteRepository, n int) {
+	random := rand.New(rand.NewSource(42))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	names := []string{"Correios", "EXPRESSO FR", "Jadlog", "Azul Cargo"}
+	services := []string{"SEDEX", "PAC", "Rodoviário"}
+
+	quotes := make([]domain.Quote, 0, n)
+	for i := 0; i < n; i++ {
+		quote := domain.Quote{
+			RecipientZipcode: domain.CEP(fmt.Sprintf("%08d", random.Intn(100_000_000))),
+			Dispatchers:      []domain.QuoteDispatcher{{Zipcode: domain.CEP(fmt.Sprintf("%08d", random.Intn(100_000_000)))}},
+			CreatedAt:        start.Add(time.Duration(random.Intn(365*24)) * time.Hour),
+		}
+		for j := 0; j < 3; j++ {
+			quote.Carrier = append(quote.Carrier, domain.Carrier{
+				Name:     names[random.Intn(len(names))],
+				Service:  services[random.Intn(len(services))],
+				Price:    domain.Money(1000 + random.Intn(100)*50),
+				Deadline: 1 + random.Intn(10),
+			})
+		}
+		quotes = append(quotes, quote)
+	}
+
+	require.NoError(t, repository.db.CreateInBatches(quotes, 100).Error)
+}
+
+func TestQuoteRepository_Aggregate_MatchesInMemory(t *testing.T) {
+	repository := NewQuoteRepository(newTestDB(t))
+	seedQuotes(t, repository, 300)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	minPrice, maxPrice := domain.Money(2000), domain.Money(4000)
+
+	filters := []domain.MetricsFilter{
+		{},
+		{LastQuotes: 10},
+		{From: &from, To: &to},
+		{From: &from, LastQuotes: 25, Carrier: "Correios"},
+		{Service: "PAC", MinPrice: &minPrice, MaxPrice: &maxPrice},
+		{DestinationZipcodePrefix: "1", OriginZipcodePrefix: "2"},
+		{Carrier: "Loggi"},
+	}
+
+	for _, filter := range filters {
+		expected, err := aggregateInMemory(repository.db, filter)
+		require.NoError(t, err)
+
+		metrics, err := repository.Aggregate(context.Background(), filter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, metrics, "%+v", filter)
+	}
+}
+
+func BenchmarkQuoteRepository_Aggregate(b *testing.B) {
+	repository := NewQuoteRepository(newTestDB(b))
+	seedQuotes(b, repository, 10_000)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	filters := []struct {
+		name   string
+		filter domain.MetricsFilter
+	}{
+		{name: "last_10", filter: domain.MetricsFilter{LastQuotes: 10}},
+		{name: "last_5000", filter: domain.MetricsFilter{LastQuotes: 5000}},
+		{name: "year", filter: domain.MetricsFilter{From: &from}},
+		{name: "year_correios", filter: domain.MetricsFilter{From: &from, Carrier: "Correios"}},
+	}
+
+	for _, f := range filters {
+		filter := f.filter
+		b.Run(f.name+"/sql", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repository.Aggregate(context.Background(), filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(f.name+"/in_memory", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := aggregateInMemory(repository.db, filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	if err := repository.CompactRollups(context.Background(), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		b.Fatal(err)
+	}
+	for _, f := range filters {
+		filter := f.filter
+		b.Run(f.name+"/rollups", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := repository.Aggregate(context.Background(), filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// withLocalTimeZone runs the rest of the test with time.Local set to loc
+func withLocalTimeZone(t *testing.T, loc *time.Location) {
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestQuoteRepository_NonUTCLocalTime(t *testing.T) {
+	withLocalTimeZone(t, time.FixedZone("-03", -3*60*60))
+	repository := NewQuoteRepository(newTestDB(t))
+
+	quotes := []domain.Quote{
+		{CreatedAt: time.Date(2024, 6, 1, 20, 0, 0, 0, time.Local), Carrier: []domain.Carrier{{Name: "Correios", Price: 1000}}},
+		{CreatedAt: time.Date(2024, 6, 1, 22, 0, 0, 0, time.Local), Carrier: []domain.Carrier{{Name: "Correios", Price: 2000}}},
+		{Carrier: []domain.Carrier{{Name: "Correios", Price: 3000}}},
+	}
+	for i := range quotes {
+		require.NoError(t, repository.Save(context.Background(), &quotes[i]))
+	}
+
+	filter := domain.QuoteFilter{Sort: domain.DefaultQuoteSort, Limit: 1}
+	var ids []uint
+	for page := 0; page < len(quotes)+1; page++ {
+		found, nextCursor, err := repository.List(context.Background(), filter)
+		require.NoError(t, err)
+		ids = append(ids, repositorytest.QuoteIDs(found)...)
+		if nextCursor == "" {
+			break
+		}
+		filter.After, err = domain.DecodeQuoteCursor(nextCursor, filter.Sort)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []uint{3, 2, 1}, ids)
+
+	from := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	listed, _, err := repository.List(context.Background(), domain.QuoteFilter{From: &from, To: &to, Sort: domain.DefaultQuoteSort, Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []uint{2}, repositorytest.QuoteIDs(listed))
+
+	metrics, err := repository.Aggregate(context.Background(), domain.MetricsFilter{From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.Window.Quotes)
+	assert.Equal(t, domain.Money(2000), metrics.Carriers["Correios"].TotalPrice)
+}
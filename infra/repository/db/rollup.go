@@ -0,0 +1,453 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Scopes of the metric rollups, telling which of carrier and service a row is grouped
+// by. The columns a row is not grouped by are empty.
+const (
+	rollupScopeCarrierService = "carrier_service"
+	rollupScopeCarrier        = "carrier"
+	rollupScopeService        = "service"
+	rollupScopeAll            = "all"
+)
+
+// rollupStateID is the ID of the single row of the metric_rollup_state table
+const rollupStateID = 1
+
+// rollupGracePeriod is how long after a day ends it may be rolled up. Saving a quote of
+// the current day leaves the state alone, so the day is only rolled up once every save
+// that started on it has committed; no transaction runs for this long.
+const rollupGracePeriod = time.Hour
+
+// metricRollup holds the totals of the carrier offers of the quotes created on one day,
+// in UTC, grouped as told by its scope. Rows grouped by carrier and service also hold
+// digests of the prices and deadlines, which are merged for the percentiles of windows
+// too large to read every offer of.
+type metricRollup struct {
+	Day             time.Time `gorm:"primaryKey"`
+	Scope           string    `gorm:"primaryKey;size:16"`
+	Carrier         string    `gorm:"primaryKey;size:191"`
+	Service         string    `gorm:"primaryKey;size:191"`
+	Quotes          int
+	Offers          int
+	TotalPriceCents int64
+	MinPriceCents   int64
+	MaxPriceCents   int64
+	PriceDigest     *utils.TDigest `gorm:"serializer:json"`
+	DeadlineDigest  *utils.TDigest `gorm:"serializer:json"`
+}
+
+func (metricRollup) TableName() string { return "metric_rollups" }
+
+// rollupState tells up to which day the quotes are rolled up: the rollups of every day
+// before RolledUpUntil are complete, and any from it on are stale until the day is rolled
+// up again. It is nil until the first compaction.
+type rollupState struct {
+	ID            uint `gorm:"primaryKey"`
+	RolledUpUntil *time.Time
+}
+
+func (rollupState) TableName() string { return "metric_rollup_state" }
+
+// rollupScopes are the groupings rolled up for each day, with the columns they group by
+var rollupScopes = []struct {
+	name    string
+	carrier string
+	service string
+}{
+	{name: rollupScopeCarrierService, carrier: "carriers.name", service: "carriers.service"},
+	{name: rollupScopeCarrier, carrier: "carriers.name", service: "''"},
+	{name: rollupScopeService, carrier: "''", service: "carriers.service"},
+	{name: rollupScopeAll, carrier: "''", service: "''"},
+}
+
+// CompactRollups rolls up the quotes of every whole day, in UTC, that ended at least
+// rollupGracePeriod before until and is not rolled up yet. The days from the earliest one
+// that got a quote after being rolled up are rolled up again.
+//
+// Each day is rolled up on its own and the state is only locked to read and move the
+// watermark, so that saving quotes never waits for more than one day to be stored.
+func (r *QuoteRepository) CompactRollups(ctx context.Context, until time.Time) error {
+	end := startOfDay(until.Add(-rollupGracePeriod))
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		day, err := r.nextRollupDay(ctx, end)
+		if err != nil {
+			return err
+		}
+		if day == nil {
+			return nil
+		}
+		if err := r.compactDay(ctx, *day); err != nil {
+			return err
+		}
+	}
+}
+
+// nextRollupDay returns the day at the watermark, or nil once it reaches end. Before the
+// first compaction the watermark is set to the day of the first quote, as no day before
+// it has anything to roll up.
+func (r *QuoteRepository) nextRollupDay(ctx context.Context, end time.Time) (*time.Time, error) {
+	var day *time.Time
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var state rollupState
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Limit(1).Find(&state, rollupStateID).Error; err != nil {
+			return err
+		}
+
+		if state.RolledUpUntil == nil {
+			start := end
+			var first []domain.Quote
+			if err := tx.Select("created_at").Order("created_at").Limit(1).Find(&first).Error; err != nil {
+				return err
+			}
+			if len(first) > 0 && startOfDay(first[0].CreatedAt).Before(end) {
+				start = startOfDay(first[0].CreatedAt)
+			}
+			if err := tx.Save(&rollupState{ID: rollupStateID, RolledUpUntil: &start}).Error; err != nil {
+				return err
+			}
+			state.RolledUpUntil = &start
+		}
+
+		if state.RolledUpUntil.Before(end) {
+			day = state.RolledUpUntil
+		}
+		return nil
+	})
+	return day, err
+}
+
+// compactDay rolls up the quotes of day and moves the watermark past it. The rollups are
+// calculated without locking the state; they are only stored if the watermark is still
+// at day and no quote of day was saved meanwhile, and calculated again otherwise.
+func (r *QuoteRepository) compactDay(ctx context.Context, day time.Time) error {
+	db := r.db.WithContext(ctx)
+	quotes, err := countDayQuotes(db, day)
+	if err != nil {
+		return err
+	}
+	rollups, err := rollUpDay(db, day)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Locking the state makes a concurrent Save of a backfilled quote of day wait
+		// until the watermark is past it, so that it moves the watermark back after it.
+		// A Save that started on day itself does not lock the state, but it committed
+		// before the grace period ended.
+		var state rollupState
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Limit(1).Find(&state, rollupStateID).Error; err != nil {
+			return err
+		}
+		if state.RolledUpUntil == nil || !state.RolledUpUntil.Equal(day) {
+			return nil
+		}
+		current, err := countDayQuotes(tx, day)
+		if err != nil || current != quotes {
+			return err
+		}
+
+		if err := tx.Where("day = ?", day).Delete(&metricRollup{}).Error; err != nil {
+			return err
+		}
+		if len(rollups) > 0 {
+			if err := tx.Create(&rollups).Error; err != nil {
+				return err
+			}
+		}
+		next := day.AddDate(0, 0, 1)
+		return tx.Save(&rollupState{ID: rollupStateID, RolledUpUntil: &next}).Error
+	})
+}
+
+// countDayQuotes counts the quotes created on day
+func countDayQuotes(db *gorm.DB, day time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&domain.Quote{}).
+		Where("created_at >= ? AND created_at < ?", day, day.AddDate(0, 0, 1)).
+		Count(&count).Error
+	return count, err
+}
+
+// RunRollupCompaction compacts the rollups up to the current day right away and then on
+// every interval, until ctx is done. Failures are logged and retried on the next tick.
+func (r *QuoteRepository) RunRollupCompaction(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.CompactRollups(ctx, time.Now()); err != nil {
+			logrus.Errorf("failed to compact the metric rollups: %s", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollUpDay calculates the rollups of every scope for the quotes created on day
+func rollUpDay(tx *gorm.DB, day time.Time) ([]metricRollup, error) {
+	var rollups []metricRollup
+	for _, scope := range rollupScopes {
+		query := tx.Model(&domain.Carrier{}).
+			Joins("JOIN quotes ON quotes.id = carriers.quote_id").
+			Where("quotes.created_at >= ? AND quotes.created_at < ?", day, day.AddDate(0, 0, 1)).
+			Select(fmt.Sprintf(
+				"%s AS carrier, %s AS service, COUNT(DISTINCT carriers.quote_id) AS quotes, COUNT(*) AS offers, "+
+					"SUM(carriers.price_cents) AS total_price_cents, MIN(carriers.price_cents) AS min_price_cents, "+
+					"MAX(carriers.price_cents) AS max_price_cents",
+				scope.carrier, scope.service,
+			))
+		if scope.carrier != "''" {
+			query = query.Group(scope.carrier)
+		}
+		if scope.service != "''" {
+			query = query.Group(scope.service)
+		}
+
+		var rows []metricRollup
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			// Without offers the ungrouped scope still gets a row, counting nothing
+			if row.Offers == 0 {
+				continue
+			}
+			row.Day = day
+			row.Scope = scope.name
+			rollups = append(rollups, row)
+		}
+	}
+	if len(rollups) == 0 {
+		return nil, nil
+	}
+
+	digests, err := dayDigests(tx, day)
+	if err != nil {
+		return nil, err
+	}
+	for i, rollup := range rollups {
+		if rollup.Scope == rollupScopeCarrierService {
+			key := carrierService{carrier: rollup.Carrier, service: rollup.Service}
+			rollups[i].PriceDigest = digests[key].price
+			rollups[i].DeadlineDigest = digests[key].deadline
+		}
+	}
+	return rollups, nil
+}
+
+// offerDigests are the digests of the prices, in centavos, and the deadlines, in days,
+// of a set of offers
+type offerDigests struct {
+	price    *utils.TDigest
+	deadline *utils.TDigest
+}
+
+// dayDigests streams the offers of the quotes created on day into digests by carrier and
+// service
+func dayDigests(tx *gorm.DB, day time.Time) (map[carrierService]offerDigests, error) {
+	rows, err := tx.Model(&domain.Carrier{}).
+		Joins("JOIN quotes ON quotes.id = carriers.quote_id").
+		Where("quotes.created_at >= ? AND quotes.created_at < ?", day, day.AddDate(0, 0, 1)).
+		Select("carriers.name, carriers.service, carriers.price_cents, carriers.deadline").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	digests := map[carrierService]offerDigests{}
+	for rows.Next() {
+		var carrier domain.Carrier
+		if err := rows.Scan(&carrier.Name, &carrier.Service, &carrier.Price, &carrier.Deadline); err != nil {
+			return nil, err
+		}
+
+		key := carrierService{carrier: carrier.Name, service: carrier.Service}
+		digest, ok := digests[key]
+		if !ok {
+			digest = offerDigests{
+				price:    utils.NewTDigest(utils.DefaultTDigestCompression),
+				deadline: utils.NewTDigest(utils.DefaultTDigestCompression),
+			}
+			digests[key] = digest
+		}
+		digest.price.Add(float64(carrier.Price))
+		digest.deadline.Add(float64(carrier.Deadline))
+	}
+	return digests, rows.Err()
+}
+
+// invalidateRollups moves the watermark back to the day of createdAt when that day is
+// already rolled up, so that the next compaction rolls it up again. The current day is
+// only rolled up once rollupGracePeriod has passed since it ended, so quotes created on
+// it leave the state alone.
+func invalidateRollups(tx *gorm.DB, createdAt, now time.Time) error {
+	day := startOfDay(createdAt)
+	if !day.Before(startOfDay(now)) {
+		return nil
+	}
+	return tx.Model(&rollupState{}).
+		Where("id = ? AND rolled_up_until > ?", rollupStateID, day).
+		Update("rolled_up_until", day).Error
+}
+
+// rollupDays are the whole days, [from, to), whose metrics are read from the rollups.
+// Without from they start at the first rollup.
+type rollupDays struct {
+	from *time.Time
+	to   time.Time
+}
+
+// splitMetricsWindow splits the window of filter into the whole days answered from the
+// rollups and the partial edges answered from the raw rows. Only filters on the window,
+// carrier and service can be answered from the rollups; for any other the whole window
+// is read from the raw rows.
+func splitMetricsWindow(db *gorm.DB, filter domain.MetricsFilter) (*rollupDays, []domain.MetricsFilter, error) {
+	whole := []domain.MetricsFilter{filter}
+	if filter.LastQuotes > 0 || filter.OriginZipcodePrefix != "" || filter.DestinationZipcodePrefix != "" ||
+		filter.MinPrice != nil || filter.MaxPrice != nil {
+		return nil, whole, nil
+	}
+
+	var state rollupState
+	if err := db.Limit(1).Find(&state, rollupStateID).Error; err != nil {
+		return nil, nil, err
+	}
+	if state.RolledUpUntil == nil {
+		return nil, whole, nil
+	}
+
+	days := rollupDays{to: *state.RolledUpUntil}
+	if filter.To != nil && filter.To.Before(days.to) {
+		days.to = startOfDay(*filter.To)
+	}
+	if filter.From != nil {
+		from := startOfDay(*filter.From)
+		if from.Before(*filter.From) {
+			from = from.AddDate(0, 0, 1)
+		}
+		if !from.Before(days.to) {
+			return nil, whole, nil
+		}
+		days.from = &from
+	}
+
+	var edges []domain.MetricsFilter
+	if filter.From != nil && filter.From.Before(*days.from) {
+		head := filter
+		head.To = days.from
+		edges = append(edges, head)
+	}
+	if filter.To == nil || days.to.Before(*filter.To) {
+		tail := filter
+		tail.From = &days.to
+		edges = append(edges, tail)
+	}
+	return &days, edges, nil
+}
+
+// rollupRows selects the rollups of the given scope for the rolled up days matching the
+// carrier and service of filter
+func rollupRows(db *gorm.DB, days rollupDays, filter domain.MetricsFilter, scope string) *gorm.DB {
+	query := db.Model(&metricRollup{}).Where("scope = ? AND day < ?", scope, days.to)
+	if days.from != nil {
+		query = query.Where("day >= ?", *days.from)
+	}
+	if filter.Carrier != "" {
+		query = query.Where("carrier = ?", filter.Carrier)
+	}
+	if filter.Service != "" {
+		query = query.Where("service = ?", filter.Service)
+	}
+	return query
+}
+
+// rollupTotals reads the totals of the offers of the rolled up days matching the
+// carrier and service of filter
+func rollupTotals(db *gorm.DB, days rollupDays, filter domain.MetricsFilter) (int, []carrierTotals, error) {
+	scope := rollupScopeAll
+	switch {
+	case filter.Carrier != "" && filter.Service != "":
+		scope = rollupScopeCarrierService
+	case filter.Carrier != "":
+		scope = rollupScopeCarrier
+	case filter.Service != "":
+		scope = rollupScopeService
+	}
+
+	var quotes int64
+	if err := rollupRows(db, days, filter, scope).Select("COALESCE(SUM(quotes), 0)").Scan(&quotes).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var totals []carrierTotals
+	err := rollupRows(db, days, filter, rollupScopeCarrierService).
+		Select("carrier AS name, service AS service, SUM(offers) AS count, SUM(total_price_cents) AS total_price, " +
+			"MIN(min_price_cents) AS min_price, MAX(max_price_cents) AS max_price").
+		Group("carrier").
+		Group("service").
+		Scan(&totals).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	return int(quotes), totals, nil
+}
+
+// mergeRollupDigests merges the digests of the rolled up days matching the carrier and
+// service of filter into stats
+func mergeRollupDigests(db *gorm.DB, days rollupDays, filter domain.MetricsFilter, stats *utils.OfferStats) error {
+	var rollups []metricRollup
+	err := rollupRows(db, days, filter, rollupScopeCarrierService).
+		Select("carrier, service, price_digest, deadline_digest").
+		Find(&rollups).Error
+	if err != nil {
+		return err
+	}
+
+	for _, rollup := range rollups {
+		stats.Merge(rollup.Carrier, rollup.Service, rollup.PriceDigest, rollup.DeadlineDigest)
+	}
+	return nil
+}
+
+// windowOffers selects the offers within the window of filter matching its carrier and
+// service, joining their quotes under the same name as matchedOffers does. Unlike
+// matchedOffers it does not go through the matching quotes, which only filters answered
+// from the rollups can skip.
+func windowOffers(db *gorm.DB, filter domain.MetricsFilter) *gorm.DB {
+	query := db.Model(&domain.Carrier{}).Joins("JOIN quotes AS matched_quotes ON matched_quotes.id = carriers.quote_id")
+	if filter.From != nil {
+		query = query.Where("matched_quotes.created_at >= ?", filter.From.UTC())
+	}
+	if filter.To != nil {
+		query = query.Where("matched_quotes.created_at < ?", filter.To.UTC())
+	}
+	query, _ = whereCarrier(query, filter.Carrier, filter.Service, nil, nil)
+	return query
+}
+
+// startOfDay returns the midnight, in UTC, that starts the day of t
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(month time.Month, day, hour int) *time.Time {
+	t := time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	return &t
+}
+
+func rolledUpUntil(t *testing.T, repository *QuoteRepository) *time.Time {
+	var state rollupState
+	require.NoError(t, repository.db.First(&state, rollupStateID).Error)
+	return state.RolledUpUntil
+}
+
+func assertAggregateMatchesInMemory(t *testing.T, repository *QuoteRepository, filters []domain.MetricsFilter) {
+	t.Helper()
+	for _, filter := range filters {
+		expected, err := aggregateInMemory(repository.db, filter)
+		require.NoError(t, err)
+
+		metrics, err := repository.Aggregate(context.Background(), filter)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, metrics, "%+v", filter)
+	}
+}
+
+func TestQuoteRepository_CompactRollups(t *testing.T) {
+	ctx := context.Background()
+	repository := NewQuoteRepository(newTestDB(t))
+	seedQuotes(t, repository, 300)
+	minPrice := domain.Money(2000)
+
+	filters := []domain.MetricsFilter{
+		{},
+		{From: date(3, 1, 0), To: date(9, 1, 0)},
+		{From: date(3, 1, 15), To: date(9, 1, 6)},
+		{From: date(3, 1, 15), Carrier: "Correios"},
+		{To: date(5, 10, 12), Service: "PAC"},
+		{From: date(6, 1, 0), Carrier: "Jadlog", Service: "SEDEX"},
+		{From: date(7, 14, 3), To: date(7, 14, 20)},
+		{From: date(3, 1, 15), LastQuotes: 25},
+		{From: date(3, 1, 15), MinPrice: &minPrice},
+		{Carrier: "Loggi"},
+	}
+
+	assertAggregateMatchesInMemory(t, repository, filters)
+
+	require.NoError(t, repository.CompactRollups(ctx, *date(7, 15, 10)))
+	assert.Equal(t, date(7, 15, 0), rolledUpUntil(t, repository))
+	assertAggregateMatchesInMemory(t, repository, filters)
+
+	backfilled := domain.Quote{
+		CreatedAt: *date(4, 2, 8),
+		Carrier:   []domain.Carrier{{Name: "Correios", Service: "PAC", Price: 1}, {Name: "Loggi", Service: "Expresso", Price: 99_999}},
+	}
+	require.NoError(t, repository.Save(ctx, &backfilled))
+	assert.Equal(t, date(4, 2, 0), rolledUpUntil(t, repository))
+	assertAggregateMatchesInMemory(t, repository, filters)
+
+	require.NoError(t, repository.CompactRollups(ctx, *date(7, 15, 10)))
+	assert.Equal(t, date(7, 15, 0), rolledUpUntil(t, repository))
+	assertAggregateMatchesInMemory(t, repository, filters)
+
+	var rollup metricRollup
+	require.NoError(t, repository.db.First(&rollup, "day = ? AND scope = ? AND carrier = ?", *date(4, 2, 0), rollupScopeCarrier, "Loggi").Error)
+	assert.Equal(t, 1, rollup.Quotes)
+	assert.Equal(t, int64(99_999), rollup.MaxPriceCents)
+}
+
+func TestQuoteRepository_CompactRollups_NoQuotes(t *testing.T) {
+	repository := NewQuoteRepository(newTestDB(t))
+
+	require.NoError(t, repository.CompactRollups(context.Background(), *date(7, 15, 10)))
+
+	assert.Equal(t, date(7, 15, 0), rolledUpUntil(t, repository))
+	var count int64
+	require.NoError(t, repository.db.Model(&metricRollup{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestQuoteRepository_Save_CurrentDay(t *testing.T) {
+	ctx := context.Background()
+	repository := NewQuoteRepository(newTestDB(t))
+	repository.now = func() time.Time { return *date(7, 15, 10) }
+	seedQuotes(t, repository, 100)
+	require.NoError(t, repository.CompactRollups(ctx, *date(7, 15, 10)))
+
+	today := domain.Quote{CreatedAt: *date(7, 15, 9), Carrier: []domain.Carrier{{Name: "Correios", Service: "PAC", Price: 1}}}
+	require.NoError(t, repository.Save(ctx, &today))
+	assert.Equal(t, date(7, 15, 0), rolledUpUntil(t, repository))
+
+	yesterday := domain.Quote{CreatedAt: *date(7, 14, 9), Carrier: []domain.Carrier{{Name: "Correios", Service: "PAC", Price: 1}}}
+	require.NoError(t, repository.Save(ctx, &yesterday))
+	assert.Equal(t, date(7, 14, 0), rolledUpUntil(t, repository))
+}
+
+func TestQuoteRepository_CompactRollups_GracePeriod(t *testing.T) {
+	repository := NewQuoteRepository(newTestDB(t))
+	seedQuotes(t, repository, 100)
+
+	require.NoError(t, repository.CompactRollups(context.Background(), date(7, 15, 0).Add(rollupGracePeriod/2)))
+	assert.Equal(t, date(7, 14, 0), rolledUpUntil(t, repository))
+
+	require.NoError(t, repository.CompactRollups(context.Background(), date(7, 15, 0).Add(rollupGracePeriod)))
+	assert.Equal(t, date(7, 15, 0), rolledUpUntil(t, repository))
+}
+
+func TestQuoteRepository_CompactRollups_NonUTCLocalTime(t *testing.T) {
+	withLocalTimeZone(t, time.FixedZone("-03", -3*60*60))
+	ctx := context.Background()
+	repository := NewQuoteRepository(newTestDB(t))
+
+	quote := domain.Quote{
+		CreatedAt: time.Date(2024, 6, 1, 22, 0, 0, 0, time.Local),
+		Carrier:   []domain.Carrier{{Name: "Correios", Service: "PAC", Price: 1000}},
+	}
+	require.NoError(t, repository.Save(ctx, &quote))
+	require.NoError(t, repository.CompactRollups(ctx, *date(6, 5, 10)))
+
+	var rollups []metricRollup
+	require.NoError(t, repository.db.Find(&rollups, "scope = ?", rollupScopeAll).Error)
+	require.Len(t, rollups, 1)
+	assert.True(t, date(6, 2, 0).Equal(rollups[0].Day))
+	assert.Equal(t, 1, rollups[0].Quotes)
+}
+
+func TestQuoteRepository_CompactDay_WatermarkMoved(t *testing.T) {
+	ctx := context.Background()
+	repository := NewQuoteRepository(newTestDB(t))
+	seedQuotes(t, repository, 100)
+	require.NoError(t, repository.CompactRollups(ctx, *date(7, 15, 10)))
+
+	// a backfill moved the watermark back while the day after it was being rolled up
+	require.NoError(t, repository.db.Save(&rollupState{ID: rollupStateID, RolledUpUntil: date(3, 1, 0)}).Error)
+	require.NoError(t, repository.compactDay(ctx, *date(3, 2, 0)))
+
+	assert.Equal(t, date(3, 1, 0), rolledUpUntil(t, repository))
+}
+
+func TestQuoteRepository_CompactRollups_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	repository := NewQuoteRepository(newTestDB(t))
+	seedQuotes(t, repository, 100)
+
+	assert.ErrorIs(t, repository.CompactRollups(ctx, *date(7, 15, 10)), context.Canceled)
+	assert.Nil(t, rolledUpUntil(t, repository))
+}
+
+func TestSplitMetricsWindow(t *testing.T) {
+	repository := NewQuoteRepository(newTestDB(t))
+	require.NoError(t, repository.CompactRollups(context.Background(), *date(7, 15, 10)))
+	minPrice := domain.Money(2000)
+
+	tests := []struct {
+		name   string
+		filter domain.MetricsFilter
+		days   *rollupDays
+		edges  []domain.MetricsFilter
+	}{
+		{
+			name:   "whole days",
+			filter: domain.MetricsFilter{From: date(3, 1, 0), To: date(4, 1, 0)},
+			days:   &rollupDays{from: date(3, 1, 0), to: *date(4, 1, 0)},
+		},
+		{
+			name:   "partial edges",
+			filter: domain.MetricsFilter{From: date(3, 1, 15), To: date(4, 1, 6)},
+			days:   &rollupDays{from: date(3, 2, 0), to: *date(4, 1, 0)},
+			edges: []domain.MetricsFilter{
+				{From: date(3, 1, 15), To: date(3, 2, 0)},
+				{From: date(4, 1, 0), To: date(4, 1, 6)},
+			},
+		},
+		{
+			name:   "past the watermark",
+			filter: domain.MetricsFilter{Carrier: "Correios"},
+			days:   &rollupDays{to: *date(7, 15, 0)},
+			edges:  []domain.MetricsFilter{{From: date(7, 15, 0), Carrier: "Correios"}},
+		},
+		{
+			name:   "within a day",
+			filter: domain.MetricsFilter{From: date(3, 1, 6), To: date(3, 1, 18)},
+			edges:  []domain.MetricsFilter{{From: date(3, 1, 6), To: date(3, 1, 18)}},
+		},
+		{
+			name:   "price band",
+			filter: domain.MetricsFilter{From: date(3, 1, 0), MinPrice: &minPrice},
+			edges:  []domain.MetricsFilter{{From: date(3, 1, 0), MinPrice: &minPrice}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			days, edges, err := splitMetricsWindow(repository.db, tt.filter)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.days, days)
+			assert.Equal(t, tt.edges, edges)
+		})
+	}
+}
+
+func TestQuoteRepository_Aggregate_ApproximateStats(t *testing.T) {
+	ctx := context.Background()
+	repository := NewQuoteRepository(newTestDB(t))
+	repository.exactStatsLimit = 100
+	seedQuotes(t, repository, 1000)
+
+	filters := []domain.MetricsFilter{
+		{From: date(3, 1, 15), To: date(9, 1, 6)},
+		{From: date(1, 1, 0), Carrier: "Correios"},
+		{LastQuotes: 500},
+	}
+
+	check := func() {
+		for _, filter := range filters {
+			expected, err := aggregateInMemory(repository.db, filter)
+			require.NoError(t, err)
+
+			metrics, err := repository.Aggregate(ctx, filter)
+
+			require.NoError(t, err)
+			assert.True(t, metrics.Approximate)
+			assert.Equal(t, repositorytest.WithoutStats(expected.Carriers), repositorytest.WithoutStats(metrics.Carriers))
+			for name, carrier := range metrics.Carriers {
+				exact := expected.Carriers[name]
+				assert.Len(t, carrier.Services, len(exact.Services), name)
+
+				assert.Equal(t, exact.PriceStats.Min, carrier.PriceStats.Min, name)
+				assert.Equal(t, exact.PriceStats.Max, carrier.PriceStats.Max, name)
+				assert.InDelta(t, float64(exact.PriceStats.Median), float64(carrier.PriceStats.Median), 100, name)
+				assert.InDelta(t, float64(exact.PriceStats.P90), float64(carrier.PriceStats.P90), 100, name)
+				assert.InDelta(t, float64(exact.PriceStats.P95), float64(carrier.PriceStats.P95), 100, name)
+				assert.InDelta(t, float64(exact.PriceStats.StdDev), float64(carrier.PriceStats.StdDev), 1, name)
+
+				assert.Equal(t, exact.DeadlineStats.Min, carrier.DeadlineStats.Min, name)
+				assert.Equal(t, exact.DeadlineStats.Max, carrier.DeadlineStats.Max, name)
+				assert.InDelta(t, exact.DeadlineStats.Median, carrier.DeadlineStats.Median, 0.5, name)
+				assert.InDelta(t, exact.DeadlineStats.P90, carrier.DeadlineStats.P90, 0.5, name)
+				assert.InDelta(t, exact.DeadlineStats.StdDev, carrier.DeadlineStats.StdDev, 0.01, name)
+			}
+		}
+	}
+
+	check()
+	require.NoError(t, repository.CompactRollups(ctx, *date(7, 15, 10)))
+	check()
+}
@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/belmadge/freteRapido/utils"
+)
+
+var _ repository.QuoteRepository = (*QuoteRepository)(nil)
+
+// QuoteRepository is an in-memory implementation of repository.QuoteRepository,
+// meant for tests and for running the service without a database
+type QuoteRepository struct {
+	mu     sync.RWMutex
+	quotes []domain.Quote
+	nextID uint
+	now    func() time.Time
+}
+
+// NewQuoteRepository creates an empty QuoteRepository
+func NewQuoteRepository() *QuoteRepository {
+	return &QuoteRepository{
+		nextID: 1,
+		now:    time.Now,
+	}
+}
+
+// Save stores a copy of the quote, assigning IDs the same way the database would
+func (r *QuoteRepository) Save(ctx context.Context, quote *domain.Quote) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	quote.ID = r.nextID
+	r.nextID++
+	if quote.CreatedAt.IsZero() {
+		quote.CreatedAt = r.now()
+	}
+	for i := range quote.Carrier {
+		quote.Carrier[i].QuoteID = quote.ID
+	}
+	for i := range quote.Dispatchers {
+		quote.Dispatchers[i].QuoteID = quote.ID
+	}
+
+	r.quotes = append(r.quotes, copyQuote(*quote))
+	return nil
+}
+
+// GetByID returns a copy of the quote with the given ID
+func (r *QuoteRepository) GetByID(ctx context.Context, id uint) (*domain.Quote, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, quote := range r.quotes {
+		if quote.ID == id {
+			found := copyQuote(quote)
+			return &found, nil
+		}
+	}
+
+	return nil, domain.ErrQuoteNotFound
+}
+
+// List returns a page of the quotes matching filter, with the same ordering and
+// cursor semantics as the database implementation
+func (r *QuoteRepository) List(ctx context.Context, filter domain.QuoteFilter) ([]domain.Quote, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var after interface{}
+	if filter.After != nil {
+		value, err := filter.After.SortValue(filter.Sort)
+		if err != nil {
+			return nil, "", domain.ErrInvalidCursor
+		}
+		after = value
+	}
+
+	var quotes []domain.Quote
+	for _, quote := range r.quotes {
+		if !matchesFilter(quote, filter) {
+			continue
+		}
+		if filter.After != nil && !isAfter(quote, filter.Sort, after, filter.After.ID) {
+			continue
+		}
+		quotes = append(quotes, copyQuote(quote))
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return isAfter(quotes[j], filter.Sort, sortValue(quotes[i], filter.Sort.Field), quotes[i].ID)
+	})
+
+	var nextCursor string
+	if len(quotes) > filter.Limit {
+		quotes = quotes[:filter.Limit]
+		nextCursor = domain.NewQuoteCursor(quotes[len(quotes)-1], filter.Sort).Encode()
+	}
+
+	return quotes, nextCursor, nil
+}
+
+// Aggregate calculates the metrics of the carrier offers matching filter
+func (r *QuoteRepository) Aggregate(ctx context.Context, filter domain.MetricsFilter) (domain.Metrics, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var quotes []domain.Quote
+	for _, quote := range r.quotes {
+		if !filter.MatchesQuote(quote) {
+			continue
+		}
+
+		var carriers []domain.Carrier
+		for _, carrier := range quote.Carrier {
+			if filter.MatchesCarrier(carrier) {
+				carriers = append(carriers, carrier)
+			}
+		}
+		if len(carriers) == 0 {
+			continue
+		}
+
+		quote = copyQuote(quote)
+		quote.Carrier = carriers
+		quotes = append(quotes, quote)
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return isAfter(quotes[j], domain.DefaultQuoteSort, quotes[i].CreatedAt, quotes[i].ID)
+	})
+	if filter.LastQuotes > 0 && len(quotes) > filter.LastQuotes {
+		quotes = quotes[:filter.LastQuotes]
+	}
+
+	metrics := utils.CalculateMetrics(quotes)
+	metrics.Window = filter.Window(len(quotes))
+	return metrics, nil
+}
+
+func matchesFilter(quote domain.Quote, filter domain.QuoteFilter) bool {
+	if filter.From != nil && quote.CreatedAt.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && !quote.CreatedAt.Before(*filter.To) {
+		return false
+	}
+	if filter.RecipientZipcode != "" && quote.RecipientZipcode != filter.RecipientZipcode {
+		return false
+	}
+
+	if filter.Carrier == "" && filter.Service == "" && filter.MinPrice == nil && filter.MaxPrice == nil {
+		return true
+	}
+	for _, carrier := range quote.Carrier {
+		if matchesCarrier(carrier, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesCarrier(carrier domain.Carrier, filter domain.QuoteFilter) bool {
+	return (filter.Carrier == "" || carrier.Name == filter.Carrier) &&
+		(filter.Service == "" || carrier.Service == filter.Service) &&
+		(filter.MinPrice == nil || carrier.Price >= *filter.MinPrice) &&
+		(filter.MaxPrice == nil || carrier.Price <= *filter.MaxPrice)
+}
+
+func sortValue(quote domain.Quote, field domain.QuoteSortField) interface{} {
+	switch field {
+	case domain.SortByTotalWeight:
+		return quote.TotalWeight
+	case domain.SortByTotalDeclaredValue:
+		return quote.TotalDeclaredValue
+	default:
+		return quote.CreatedAt
+	}
+}
+
+// isAfter reports whether quote comes after the position given by value and id in the
+// sort order
+func isAfter(quote domain.Quote, quoteSort domain.QuoteSort, value interface{}, id uint) bool {
+	comparison := compare(sortValue(quote, quoteSort.Field), value)
+	if comparison == 0 {
+		comparison = compare(quote.ID, id)
+	}
+	if quoteSort.Descending {
+		return comparison < 0
+	}
+	return comparison > 0
+}
+
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case domain.Money:
+		b := b.(domain.Money)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case float64:
+		b := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	case uint:
+		b := b.(uint)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// copyQuote deep copies a quote so that callers cannot change the stored one
+func copyQuote(quote domain.Quote) domain.Quote {
+	quote.SimulationType = append([]int(nil), quote.SimulationType...)
+	quote.Carrier = append([]domain.Carrier(nil), quote.Carrier...)
+
+	dispatchers := quote.Dispatchers
+	quote.Dispatchers = nil
+	for _, dispatcher := range dispatchers {
+		dispatcher.Volumes = append([]domain.QuoteVolume(nil), dispatcher.Volumes...)
+		quote.Dispatchers = append(quote.Dispatchers, dispatcher)
+	}
+
+	return quote
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/belmadge/freteRapido/infra/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newEmptyRepository creates an empty repository for the shared repository tests
+func newEmptyRepository(t *testing.T) repository.QuoteRepository {
+	return NewQuoteRepository()
+}
+
+func TestQuoteRepository_SaveAndGetByID(t *testing.T) {
+	repository := NewQuoteRepository()
+
+	quote := domain.Quote{RecipientZipcode: "29161376", Carrier: []domain.Carrier{{Name: "Correios", Price: 2099}}}
+	assert.NoError(t, repository.Save(context.Background(), &quote))
+	assert.Equal(t, uint(1), quote.ID)
+	assert.False(t, quote.CreatedAt.IsZero())
+
+	found, err := repository.GetByID(context.Background(), quote.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, quote, *found)
+
+	found.Carrier[0].Price = 0
+	stored, _ := repository.GetByID(context.Background(), quote.ID)
+	assert.Equal(t, domain.Money(2099), stored.Carrier[0].Price, "changing a returned quote does not change the stored one")
+
+	_, err = repository.GetByID(context.Background(), 2)
+	assert.ErrorIs(t, err, domain.ErrQuoteNotFound)
+}
+
+func TestQuoteRepository_List(t *testing.T) {
+	repositorytest.TestList(t, newEmptyRepository)
+}
+
+func TestQuoteRepository_List_Pagination(t *testing.T) {
+	repositorytest.TestListPagination(t, newEmptyRepository)
+}
+
+func TestQuoteRepository_Aggregate(t *testing.T) {
+	repositorytest.TestAggregate(t, newEmptyRepository)
+}
+
+func TestQuoteRepository_Aggregate_Filters(t *testing.T) {
+	repositorytest.TestAggregateFilters(t, newEmptyRepository)
+}
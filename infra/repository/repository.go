@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/belmadge/freteRapido/domain"
+)
+
+// QuoteRepository stores the quotes and answers the queries made over them
+type QuoteRepository interface {
+	// Save stores a new quote with its request and carriers, filling in its ID and CreatedAt
+	Save(ctx context.Context, quote *domain.Quote) error
+
+	// GetByID returns the quote with the given ID or domain.ErrQuoteNotFound
+	GetByID(ctx context.Context, id uint) (*domain.Quote, error)
+
+	// List returns a page of the quotes matching filter and the cursor of the next
+	// page, which is empty on the last page
+	List(ctx context.Context, filter domain.QuoteFilter) ([]domain.Quote, string, error)
+
+	// Aggregate calculates the carrier metrics of the quotes matching filter
+	Aggregate(ctx context.Context, filter domain.MetricsFilter) (domain.Metrics, error)
+}
@@ -0,0 +1,143 @@
+// Package repositorytest holds the tests every repository.QuoteRepository implementation
+// runs, so that they answer the same queries the same way
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// WithoutStats keeps the totals of the carrier metrics, leaving out the spread of the
+// prices and deadlines and the metrics by service
+func WithoutStats(carriers map[string]domain.CarrierMetrics) map[string]domain.CarrierMetrics {
+	totals := make(map[string]domain.CarrierMetrics, len(carriers))
+	for name, metrics := range carriers {
+		totals[name] = domain.CarrierMetrics{Count: metrics.Count, TotalPrice: metrics.TotalPrice, AveragePrice: metrics.AveragePrice}
+	}
+	return totals
+}
+
+// seedAggregateQuotes saves the quotes of SeedQuotes and, on the fifth day, a quote with
+// two offers
+func seedAggregateQuotes(t *testing.T, repository repository.QuoteRepository) {
+	SeedQuotes(t, repository)
+	quote := domain.Quote{
+		RecipientZipcode: "01310100",
+		Dispatchers:      []domain.QuoteDispatcher{{Zipcode: "88010000"}},
+		Carrier: []domain.Carrier{
+			{Name: "Correios", Service: "SEDEX", Price: 5000},
+			{Name: "Jadlog", Service: ".Package", Price: 2500},
+		},
+		CreatedAt: seedStart.AddDate(0, 0, 4),
+	}
+	require.NoError(t, repository.Save(context.Background(), &quote))
+}
+
+// TestAggregate checks the currency and totals of the latest quotes, on an empty
+// repository created by newRepository
+func TestAggregate(t *testing.T, newRepository func(t *testing.T) repository.QuoteRepository) {
+	repository := newRepository(t)
+	SeedQuotes(t, repository)
+
+	metrics, err := repository.Aggregate(context.Background(), domain.MetricsFilter{LastQuotes: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.CurrencyBRL, metrics.Currency)
+	assert.Equal(t, map[string]domain.CarrierMetrics{
+		"Correios":    {Count: 1, TotalPrice: 4500, AveragePrice: 4500},
+		"EXPRESSO FR": {Count: 1, TotalPrice: 1700, AveragePrice: 1700},
+	}, WithoutStats(metrics.Carriers))
+}
+
+// TestAggregateFilters checks the totals aggregated for each kind of metrics filter, on
+// the empty repositories created by newRepository
+func TestAggregateFilters(t *testing.T, newRepository func(t *testing.T) repository.QuoteRepository) {
+	from := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC)
+	minPrice, maxPrice := domain.Money(2000), domain.Money(4000)
+
+	tests := []struct {
+		name     string
+		filter   domain.MetricsFilter
+		carriers map[string]domain.CarrierMetrics
+		quotes   int
+	}{
+		{
+			name:   "date range",
+			filter: domain.MetricsFilter{From: &from, To: &to},
+			carriers: map[string]domain.CarrierMetrics{
+				"Correios":    {Count: 1, TotalPrice: 1500, AveragePrice: 1500},
+				"EXPRESSO FR": {Count: 1, TotalPrice: 1700, AveragePrice: 1700},
+			},
+			quotes: 2,
+		},
+		{
+			name:   "carrier",
+			filter: domain.MetricsFilter{Carrier: "Correios"},
+			carriers: map[string]domain.CarrierMetrics{
+				"Correios": {Count: 4, TotalPrice: 14000, AveragePrice: 3500},
+			},
+			quotes: 4,
+		},
+		{
+			name:   "latest quotes of a carrier and service",
+			filter: domain.MetricsFilter{Carrier: "Correios", Service: "SEDEX", LastQuotes: 2},
+			carriers: map[string]domain.CarrierMetrics{
+				"Correios": {Count: 2, TotalPrice: 9500, AveragePrice: 4750},
+			},
+			quotes: 2,
+		},
+		{
+			name:   "destination zipcode prefix",
+			filter: domain.MetricsFilter{DestinationZipcodePrefix: "01"},
+			carriers: map[string]domain.CarrierMetrics{
+				"Correios": {Count: 3, TotalPrice: 12500, AveragePrice: 4167},
+				"Jadlog":   {Count: 1, TotalPrice: 2500, AveragePrice: 2500},
+			},
+			quotes: 3,
+		},
+		{
+			name:   "origin zipcode prefix",
+			filter: domain.MetricsFilter{OriginZipcodePrefix: "880"},
+			carriers: map[string]domain.CarrierMetrics{
+				"Correios": {Count: 1, TotalPrice: 5000, AveragePrice: 5000},
+				"Jadlog":   {Count: 1, TotalPrice: 2500, AveragePrice: 2500},
+			},
+			quotes: 1,
+		},
+		{
+			name:   "price band",
+			filter: domain.MetricsFilter{MinPrice: &minPrice, MaxPrice: &maxPrice},
+			carriers: map[string]domain.CarrierMetrics{
+				"Correios": {Count: 1, TotalPrice: 3000, AveragePrice: 3000},
+				"Jadlog":   {Count: 1, TotalPrice: 2500, AveragePrice: 2500},
+			},
+			quotes: 2,
+		},
+		{
+			name:     "no matching offers",
+			filter:   domain.MetricsFilter{Service: "Aéreo"},
+			carriers: map[string]domain.CarrierMetrics{},
+			quotes:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := newRepository(t)
+			seedAggregateQuotes(t, repository)
+
+			metrics, err := repository.Aggregate(context.Background(), tt.filter)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.carriers, WithoutStats(metrics.Carriers))
+			assert.Equal(t, tt.filter.Window(tt.quotes), metrics.Window)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestList checks the quotes listed for each kind of quote filter and sort, on the empty
+// repositories created by newRepository
+func TestList(t *testing.T, newRepository func(t *testing.T) repository.QuoteRepository) {
+	from := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	minPrice, maxPrice := domain.Money(1600), domain.Money(4000)
+
+	tests := []struct {
+		name     string
+		filter   domain.QuoteFilter
+		expected []uint
+	}{
+		{
+			name:     "newest first",
+			filter:   domain.QuoteFilter{Sort: domain.DefaultQuoteSort, Limit: 10},
+			expected: []uint{4, 3, 2, 1},
+		},
+		{
+			name:     "by total weight with id as tie breaker",
+			filter:   domain.QuoteFilter{Sort: domain.QuoteSort{Field: domain.SortByTotalWeight}, Limit: 10},
+			expected: []uint{2, 1, 3, 4},
+		},
+		{
+			name:     "from date and recipient zipcode",
+			filter:   domain.QuoteFilter{From: &from, RecipientZipcode: "29161376", Sort: domain.DefaultQuoteSort, Limit: 10},
+			expected: []uint{3, 2},
+		},
+		{
+			name:     "carrier and service",
+			filter:   domain.QuoteFilter{Carrier: "Correios", Service: "SEDEX", Sort: domain.DefaultQuoteSort, Limit: 10},
+			expected: []uint{4, 1},
+		},
+		{
+			name:     "price range",
+			filter:   domain.QuoteFilter{MinPrice: &minPrice, MaxPrice: &maxPrice, Sort: domain.DefaultQuoteSort, Limit: 10},
+			expected: []uint{3, 1},
+		},
+	}
+
+	repository := newRepository(t)
+	SeedQuotes(t, repository)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotes, nextCursor, err := repository.List(context.Background(), tt.filter)
+			assert.NoError(t, err)
+			assert.Empty(t, nextCursor)
+			assert.Equal(t, tt.expected, QuoteIDs(quotes))
+		})
+	}
+}
+
+// TestListPagination checks that the cursor of a page lists the quotes after it, on an
+// empty repository created by newRepository
+func TestListPagination(t *testing.T, newRepository func(t *testing.T) repository.QuoteRepository) {
+	repository := newRepository(t)
+	SeedQuotes(t, repository)
+	filter := domain.QuoteFilter{Sort: domain.QuoteSort{Field: domain.SortByTotalWeight, Descending: true}, Limit: 3}
+
+	quotes, nextCursor, err := repository.List(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{4, 3, 1}, QuoteIDs(quotes))
+	assert.NotEmpty(t, nextCursor)
+
+	filter.After, err = domain.DecodeQuoteCursor(nextCursor, filter.Sort)
+	assert.NoError(t, err)
+
+	quotes, nextCursor, err = repository.List(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{2}, QuoteIDs(quotes))
+	assert.Empty(t, nextCursor)
+}
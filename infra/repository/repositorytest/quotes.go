@@ -0,0 +1,38 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// seedStart is the day the first quote of SeedQuotes was created
+var seedStart = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+// SeedQuotes saves a quote with a single offer on each of the first four days of June
+// 2024, which an empty repository numbers 1 to 4
+func SeedQuotes(t *testing.T, repository repository.QuoteRepository) {
+	quotes := []domain.Quote{
+		{RecipientZipcode: "01001000", TotalWeight: 5, Carrier: []domain.Carrier{{Name: "Correios", Service: "SEDEX", Price: 3000}}},
+		{RecipientZipcode: "29161376", TotalWeight: 3, Carrier: []domain.Carrier{{Name: "Correios", Service: "PAC", Price: 1500}}},
+		{RecipientZipcode: "29161376", TotalWeight: 5, Carrier: []domain.Carrier{{Name: "EXPRESSO FR", Service: "Rodoviário", Price: 1700}}},
+		{RecipientZipcode: "01001000", TotalWeight: 8, Carrier: []domain.Carrier{{Name: "Correios", Service: "SEDEX", Price: 4500}}},
+	}
+	for i := range quotes {
+		quotes[i].CreatedAt = seedStart.AddDate(0, 0, i)
+		require.NoError(t, repository.Save(context.Background(), &quotes[i]))
+	}
+}
+
+// QuoteIDs returns the ids of quotes, in order
+func QuoteIDs(quotes []domain.Quote) []uint {
+	ids := make([]uint, 0, len(quotes))
+	for _, quote := range quotes {
+		ids = append(ids, quote.ID)
+	}
+	return ids
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the upstream while its circuit breaker is open
+var ErrCircuitOpen = errors.New("upstream unavailable: circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreakerStatus is a snapshot of a CircuitBreaker, as reported by the health check
+type CircuitBreakerStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker stops calling an upstream after failureThreshold consecutive failures.
+// Once openTimeout has passed a single probe request is let through: if it succeeds the
+// circuit closes again, otherwise it stays open for another openTimeout.
+type CircuitBreaker struct {
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker identified by name
+func NewCircuitBreaker(name string, failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            CircuitClosed,
+		now:              time.Now,
+	}
+}
+
+// Name returns the name of the upstream protected by the breaker
+func (b *CircuitBreaker) Name() string {
+	return b.name
+}
+
+// Status returns the current state of the breaker
+func (b *CircuitBreaker) Status() CircuitBreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := CircuitBreakerStatus{
+		State:               b.currentState(),
+		ConsecutiveFailures: b.failures,
+	}
+	if status.State != CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+
+	return status
+}
+
+// Allow reports whether a request may be sent to the upstream
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// Success records a successful call, closing the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed call, opening the circuit once the threshold is reached or
+// when the probe of a half-open circuit fails
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.probing || b.failures >= b.failureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+	b.probing = false
+}
+
+// abort releases the probe of a half-open circuit without recording an outcome
+func (b *CircuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// currentState moves an open circuit to half-open once the open timeout expires.
+// It must be called with the lock held.
+func (b *CircuitBreaker) currentState() CircuitState {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.state = CircuitHalfOpen
+	}
+	return b.state
+}
+
+// CircuitBreakerClient is an HTTPClient that guards another client with a CircuitBreaker
+type CircuitBreakerClient struct {
+	client  HTTPClient
+	breaker *CircuitBreaker
+}
+
+// NewCircuitBreakerClient wraps client so that requests fail fast while breaker is open
+func NewCircuitBreakerClient(client HTTPClient, breaker *CircuitBreaker) *CircuitBreakerClient {
+	return &CircuitBreakerClient{
+		client:  client,
+		breaker: breaker,
+	}
+}
+
+// Do sends the request unless the circuit is open. Transport errors and 5xx responses
+// count as failures; requests cancelled by the caller are not counted at all.
+func (c *CircuitBreakerClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		c.breaker.abort()
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		c.breaker.Failure()
+	default:
+		c.breaker.Success()
+	}
+
+	return resp, err
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/infra/repository/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	breaker := NewCircuitBreaker("provider1", 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	assert.NoError(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, CircuitClosed, breaker.Status().State)
+
+	assert.NoError(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, CircuitOpen, breaker.Status().State)
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, CircuitHalfOpen, breaker.Status().State)
+	assert.NoError(t, breaker.Allow())
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen, "only one probe is allowed while half-open")
+
+	breaker.Failure()
+	assert.Equal(t, CircuitOpen, breaker.Status().State)
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, breaker.Allow())
+	breaker.Success()
+
+	status := breaker.Status()
+	assert.Equal(t, CircuitClosed, status.State)
+	assert.Equal(t, 0, status.ConsecutiveFailures)
+	assert.Nil(t, status.OpenedAt)
+}
+
+func TestCircuitBreakerClient_Do(t *testing.T) {
+	calls := 0
+	breaker := NewCircuitBreaker("provider1", 2, time.Minute)
+	client := NewCircuitBreakerClient(&mock.MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			calls++
+			if calls == 1 {
+				return newStringResponse(500, ``), nil
+			}
+			return nil, errors.New("connection refused")
+		},
+	}, breaker)
+
+	req, _ := http.NewRequest(http.MethodPost, FreteRapidoSimulateURL, nil)
+
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 500, resp.StatusCode)
+
+	_, err = client.Do(req)
+	assert.EqualError(t, err, "connection refused")
+
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, calls, "the upstream is not called while the circuit is open")
+}
+
+func TestCircuitBreakerClient_Do_CanceledIsNotAFailure(t *testing.T) {
+	breaker := NewCircuitBreaker("provider1", 1, time.Minute)
+	client := NewCircuitBreakerClient(&mock.MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			return nil, req.Context().Err()
+		},
+	}, breaker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, FreteRapidoSimulateURL, nil)
+
+	_, err := client.Do(req)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, CircuitClosed, breaker.Status().State)
+}
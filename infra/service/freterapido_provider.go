@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/belmadge/freteRapido/domain"
+)
+
+const (
+	// FreteRapidoSimulateURL is the Frete Rápido endpoint used to simulate quotes
+	FreteRapidoSimulateURL = "https://sp.freterapido.com/api/v3/quote/simulate"
+
+	// FreteRapidoProviderName identifies the offers that came from Frete Rápido
+	FreteRapidoProviderName = "frete_rapido"
+)
+
+// HTTPClient is the subset of *http.Client used by the providers
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// FreteRapidoProvider is a QuoteProvider backed by the Frete Rápido simulate API
+type FreteRapidoProvider struct {
+	client HTTPClient
+	url    string
+}
+
+// NewFreteRapidoProvider creates a provider that sends its requests to url through client
+func NewFreteRapidoProvider(client HTTPClient, url string) *FreteRapidoProvider {
+	return &FreteRapidoProvider{
+		client: client,
+		url:    url,
+	}
+}
+
+// Name returns the name the offers of this provider are tagged with
+func (p *FreteRapidoProvider) Name() string {
+	return FreteRapidoProviderName
+}
+
+// Quote requests offers for the given input from the Frete Rápido API
+func (p *FreteRapidoProvider) Quote(ctx context.Context, input domain.QuoteRequest) ([]domain.Carrier, error) {
+	requestBody, err := json.Marshal(freteRapidoPayload(input))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newUpstreamError(resp, "failed to get quote from Frete Rápido")
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	simulateResponse, err := DecodeSimulateResponse(bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return simulateResponse.Carriers(), nil
+}
+
+// freteRapidoPayload builds the body of a simulate request. Frete Rápido takes the
+// zipcodes as integers, so the leading zeros of a CEP are dropped. The optional fields
+// are only sent when set, leaving Frete Rápido's defaults in place otherwise.
+func freteRapidoPayload(input domain.QuoteRequest) map[string]interface{} {
+	recipient := map[string]interface{}{
+		"type":    input.Recipient.Type,
+		"country": input.Recipient.Country,
+		"zipcode": input.Recipient.Zipcode.Int(),
+	}
+	if input.Recipient.RegisteredNumber != "" {
+		recipient["registered_number"] = input.Recipient.RegisteredNumber
+	}
+	if input.Recipient.StateInscription != "" {
+		recipient["state_inscription"] = input.Recipient.StateInscription
+	}
+
+	payload := map[string]interface{}{
+		"shipper": map[string]string{
+			"registered_number": string(input.Shipper.RegisteredNumber),
+			"token":             input.Shipper.Token,
+			"platform_code":     input.Shipper.PlatformCode,
+		},
+		"recipient":       recipient,
+		"dispatchers":     freteRapidoDispatchers(input.Dispatchers),
+		"simulation_type": input.SimulationType,
+	}
+	if input.Channel != "" {
+		payload["channel"] = input.Channel
+	}
+	if input.Identification != "" {
+		payload["identification"] = input.Identification
+	}
+	if input.Filter != 0 {
+		payload["filter"] = input.Filter
+	}
+	if input.Limit != 0 {
+		payload["limit"] = input.Limit
+	}
+	if input.Reverse {
+		payload["reverse"] = true
+	}
+	if input.Returns != nil {
+		payload["returns"] = input.Returns
+	}
+	return payload
+}
+
+func freteRapidoDispatchers(dispatchers []domain.Dispatcher) []map[string]interface{} {
+	payload := make([]map[string]interface{}, 0, len(dispatchers))
+	for _, dispatcher := range dispatchers {
+		entry := map[string]interface{}{
+			"registered_number": dispatcher.RegisteredNumber,
+			"zipcode":           dispatcher.Zipcode.Int(),
+			"volumes":           dispatcher.Volumes,
+		}
+		if dispatcher.TotalPrice != 0 {
+			entry["total_price"] = dispatcher.TotalPrice
+		}
+		payload = append(payload, entry)
+	}
+	return payload
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/belmadge/freteRapido/infra/repository/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStringResponse(statusCode int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func validQuoteRequest() domain.QuoteRequest {
+	return domain.QuoteRequest{
+		Shipper: domain.Shipper{
+			RegisteredNumber: "25438296000158",
+			Token:            "token",
+			PlatformCode:     "platform",
+		},
+		Recipient: domain.Recipient{
+			Country: "BRA",
+			Zipcode: "12345678",
+		},
+		Dispatchers: []domain.Dispatcher{
+			{
+				RegisteredNumber: "25438296000158",
+				Zipcode:          "12345678",
+				Volumes: []domain.Volume{
+					{
+						Category:      "7",
+						Amount:        1,
+						UnitaryWeight: 5,
+						UnitaryPrice:  34900,
+						Height:        0.2,
+						Width:         0.2,
+						Length:        0.2,
+					},
+				},
+			},
+		},
+		SimulationType: []int{0},
+	}
+}
+
+func TestFreteRapidoProvider_Quote_Success(t *testing.T) {
+	client := &mock.MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			assert.Equal(t, http.MethodPost, req.Method)
+			assert.Equal(t, FreteRapidoSimulateURL, req.URL.String())
+			assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+			var payload struct {
+				Recipient   struct{ Zipcode int }
+				Dispatchers []struct {
+					Zipcode int
+					Volumes []map[string]interface{}
+				}
+			}
+			assert.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+			assert.Equal(t, 1310100, payload.Recipient.Zipcode, "Frete Rápido takes zipcodes as integers")
+			assert.Equal(t, 12345678, payload.Dispatchers[0].Zipcode)
+			assert.Equal(t, map[string]interface{}{
+				"amount": 1.0, "amount_volumes": 2.0, "category": "7", "sku": "abc-teste-123", "manufacturer": "ACME",
+				"height": 0.2, "width": 0.2, "length": 0.2, "unitary_price": 349.0, "unitary_weight": 5.0, "rotate": true,
+			}, payload.Dispatchers[0].Volumes[0])
+
+			return newStringResponse(200, `{
+				"dispatchers": [{
+					"offers": [{
+						"carrier": {"name": "Carrier1"},
+						"final_price": 10.0,
+						"service": "Service1",
+						"delivery_time": {"days": 2}
+					}]
+				}]
+			}`), nil
+		},
+	}
+
+	provider := NewFreteRapidoProvider(client, FreteRapidoSimulateURL)
+	input := validQuoteRequest()
+	input.Recipient.Zipcode = "01310100"
+	volume := &input.Dispatchers[0].Volumes[0]
+	volume.SKU, volume.Manufacturer, volume.AmountVolumes, volume.Rotate = "abc-teste-123", "ACME", 2, true
+
+	expected := []domain.Carrier{
+		{Name: "Carrier1", Price: 1000, Currency: domain.CurrencyBRL, Service: "Service1", Deadline: 2},
+	}
+
+	carriers, err := provider.Quote(context.Background(), input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, carriers)
+}
+
+func TestFreteRapidoPayload(t *testing.T) {
+	minimal := validQuoteRequest()
+
+	full := validQuoteRequest()
+	full.Recipient.Type = domain.RecipientTypeCompany
+	full.Recipient.RegisteredNumber = "04884082000135"
+	full.Recipient.StateInscription = "ISENTO"
+	full.Dispatchers[0].TotalPrice = 50000
+	full.Channel = "marketplace"
+	full.Identification = "pedido-123"
+	full.Filter = domain.QuoteFilterCheapest
+	full.Limit = 3
+	full.Reverse = true
+	full.Returns = &domain.QuoteReturns{Composition: true}
+
+	tests := []struct {
+		name     string
+		input    domain.QuoteRequest
+		expected string
+	}{
+		{
+			name:  "without options",
+			input: minimal,
+			expected: `{
+				"shipper": {"registered_number": "25438296000158", "token": "token", "platform_code": "platform"},
+				"recipient": {"type": 0, "country": "BRA", "zipcode": 12345678},
+				"dispatchers": [{"registered_number": "25438296000158", "zipcode": 12345678, "volumes": [
+					{"amount": 1, "category": "7", "height": 0.2, "width": 0.2, "length": 0.2, "unitary_price": 349.00, "unitary_weight": 5}
+				]}],
+				"simulation_type": [0]
+			}`,
+		},
+		{
+			name:  "with every option",
+			input: full,
+			expected: `{
+				"shipper": {"registered_number": "25438296000158", "token": "token", "platform_code": "platform"},
+				"recipient": {"type": 1, "registered_number": "04884082000135", "state_inscription": "ISENTO", "country": "BRA", "zipcode": 12345678},
+				"dispatchers": [{"registered_number": "25438296000158", "zipcode": 12345678, "total_price": 500.00, "volumes": [
+					{"amount": 1, "category": "7", "height": 0.2, "width": 0.2, "length": 0.2, "unitary_price": 349.00, "unitary_weight": 5}
+				]}],
+				"simulation_type": [0],
+				"channel": "marketplace",
+				"identification": "pedido-123",
+				"filter": 1,
+				"limit": 3,
+				"reverse": true,
+				"returns": {"composition": true, "volumes": false, "applied_rules": false}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(freteRapidoPayload(tt.input))
+
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(body))
+		})
+	}
+}
+
+func TestFreteRapidoProvider_Quote_Error(t *testing.T) {
+	tests := []struct {
+		name        string
+		doFunc      func(req *http.Request) (*http.Response, error)
+		expectedErr string
+	}{
+		{
+			name: "http request error",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("http request error")
+			},
+			expectedErr: "http request error",
+		},
+		{
+			name: "non-200 response",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				return newStringResponse(500, `{}`), nil
+			},
+			expectedErr: "failed to get quote from Frete Rápido",
+		},
+		{
+			name: "invalid response body",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				return newStringResponse(200, `invalid`), nil
+			},
+			expectedErr: "invalid Frete Rápido response: invalid character 'i' looking for beginning of value",
+		},
+		{
+			name: "validation response error",
+			doFunc: func(req *http.Request) (*http.Response, error) {
+				return newStringResponse(200, `{
+					"dispatchers": [{
+						"offers": [{
+							"carrier": {"name": "Carrier1"},
+							"final_price": 10.0,
+							"service": "Service1",
+							"delivery_time": {"invalid_field": 1}
+						}]
+					}]
+				}`), nil
+			},
+			expectedErr: "invalid Frete Rápido response at dispatchers[0].offers[0].delivery_time: missing days, hours, or minutes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewFreteRapidoProvider(&mock.MockHTTPClient{DoFunc: tt.doFunc}, FreteRapidoSimulateURL)
+
+			carriers, err := provider.Quote(context.Background(), validQuoteRequest())
+
+			assert.Nil(t, carriers)
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
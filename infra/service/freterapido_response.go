@@ -0,0 +1,366 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+)
+
+// SimulateResponse is the body returned by the Frete Rápido simulate endpoint
+type SimulateResponse struct {
+	Dispatchers []SimulateDispatcher `json:"dispatchers"`
+}
+
+type SimulateDispatcher struct {
+	ID                         string  `json:"id"`
+	RequestID                  string  `json:"request_id"`
+	RegisteredNumberShipper    string  `json:"registered_number_shipper"`
+	RegisteredNumberDispatcher string  `json:"registered_number_dispatcher"`
+	ZipcodeOrigin              int     `json:"zipcode_origin"`
+	Offers                     []Offer `json:"offers"`
+}
+
+type Offer struct {
+	Offer                       int           `json:"offer"`
+	TableReference              string        `json:"table_reference"`
+	SimulationType              int           `json:"simulation_type"`
+	Carrier                     OfferCarrier  `json:"carrier"`
+	Service                     string        `json:"service"`
+	ServiceCode                 string        `json:"service_code"`
+	ServiceDescription          string        `json:"service_description"`
+	DeliveryTime                DeliveryTime  `json:"delivery_time"`
+	OriginalDeliveryTime        *DeliveryTime `json:"original_delivery_time"`
+	CarrierOriginalDeliveryTime *DeliveryTime `json:"carrier_original_delivery_time"`
+	Expiration                  string        `json:"expiration"`
+	CostPrice                   domain.Money  `json:"cost_price"`
+	FinalPrice                  domain.Money  `json:"final_price"`
+	Weights                     Weights       `json:"weights"`
+	Composition                 *Composition  `json:"composition"`
+	HomeDelivery                bool          `json:"home_delivery"`
+	Identifier                  string        `json:"identifier"`
+	DeliveryNote                string        `json:"delivery_note"`
+}
+
+type OfferCarrier struct {
+	Name             string `json:"name"`
+	RegisteredNumber string `json:"registered_number"`
+	StateInscription string `json:"state_inscription"`
+	Logo             string `json:"logo"`
+	Reference        int    `json:"reference"`
+	CompanyName      string `json:"company_name"`
+}
+
+// DeliveryTime is how long an offer takes to be delivered. Any of days, hours and minutes
+// may be omitted by the upstream, so they are kept as pointers.
+type DeliveryTime struct {
+	Days          *int   `json:"days"`
+	Hours         *int   `json:"hours"`
+	Minutes       *int   `json:"minutes"`
+	EstimatedDate string `json:"estimated_date"`
+}
+
+type Weights struct {
+	Real  float64 `json:"real"`
+	Cubed float64 `json:"cubed"`
+	Used  float64 `json:"used"`
+}
+
+// Composition is the breakdown of the price of an offer into amounts of reais
+type Composition struct {
+	FreightWeight       domain.Money `json:"freight_weight"`
+	FreightWeightExcess domain.Money `json:"freight_weight_excess"`
+	FreightWeightVolume domain.Money `json:"freight_weight_volume"`
+	FreightVolume       domain.Money `json:"freight_volume"`
+	FreightMinimum      domain.Money `json:"freight_minimum"`
+	FreightInvoice      domain.Money `json:"freight_invoice"`
+	SubTotal1           domain.Money `json:"sub_total1"`
+	SubTotal2           domain.Money `json:"sub_total2"`
+	SubTotal3           domain.Money `json:"sub_total3"`
+	Dispatch            domain.Money `json:"dispatch"`
+	AdValorem           domain.Money `json:"ad_valorem"`
+	Gris                domain.Money `json:"gris"`
+	Tde                 domain.Money `json:"tde"`
+	Tda                 domain.Money `json:"tda"`
+	Trt                 domain.Money `json:"trt"`
+	Tas                 domain.Money `json:"tas"`
+	Toll                domain.Money `json:"toll"`
+	Suframa             domain.Money `json:"suframa"`
+	Tax                 domain.Money `json:"tax"`
+}
+
+// Deadline returns the delivery time in days, preferring days over minutes over hours
+func (d DeliveryTime) Deadline() (int, bool) {
+	switch {
+	case d.Days != nil:
+		return *d.Days, true
+	case d.Minutes != nil:
+		return *d.Minutes / 1440, true
+	case d.Hours != nil:
+		return *d.Hours / 24, true
+	default:
+		return 0, false
+	}
+}
+
+// EstimatedDeliveryDate parses the estimated date, which is given either as a plain date
+// or as a timestamp. It returns nil when the upstream does not estimate a date.
+func (d DeliveryTime) EstimatedDeliveryDate() (*time.Time, error) {
+	return parseUpstreamTime(d.EstimatedDate)
+}
+
+// ExpiresAt parses the time until which the offer can be contracted
+func (o Offer) ExpiresAt() (*time.Time, error) {
+	return parseUpstreamTime(o.Expiration)
+}
+
+func parseUpstreamTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid date %q", value)
+}
+
+// ResponseDecodeError is returned when the Frete Rápido response cannot be decoded.
+// Path is the JSON path of the value that failed, such as dispatchers[0].offers[1].final_price.
+type ResponseDecodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *ResponseDecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("invalid Frete Rápido response: %s", e.Err)
+	}
+	return fmt.Sprintf("invalid Frete Rápido response at %s: %s", e.Path, e.Err)
+}
+
+func (e *ResponseDecodeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errMissingField        = errors.New("missing required field")
+	errMissingDeliveryTime = errors.New("missing days, hours, or minutes")
+)
+
+// offerRequiredFields are the offer fields needed to build a domain.Carrier
+var offerRequiredFields = []string{"carrier", "service", "final_price", "delivery_time"}
+
+// offerMoneyFields are decoded on their own, as encoding/json does not always report
+// the path of the errors returned by domain.Money
+var offerMoneyFields = []string{"cost_price", "final_price"}
+
+// compositionMoneyFields are the fields of Composition, which are all amounts
+var compositionMoneyFields = jsonFields(reflect.TypeOf(Composition{}))
+
+// DecodeSimulateResponse decodes and validates the body of a simulate response. Each
+// dispatcher and offer is decoded on its own so that errors carry their index.
+func DecodeSimulateResponse(body []byte) (*SimulateResponse, error) {
+	var raw struct {
+		Dispatchers *[]json.RawMessage `json:"dispatchers"`
+	}
+	if err := decodeAt("", body, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Dispatchers == nil {
+		return nil, &ResponseDecodeError{Path: "dispatchers", Err: errMissingField}
+	}
+
+	response := &SimulateResponse{
+		Dispatchers: make([]SimulateDispatcher, len(*raw.Dispatchers)),
+	}
+
+	for i, rawDispatcher := range *raw.Dispatchers {
+		dispatcherPath := fmt.Sprintf("dispatchers[%d]", i)
+
+		var dispatcher struct {
+			SimulateDispatcher
+			Offers *[]json.RawMessage `json:"offers"`
+		}
+		if err := decodeAt(dispatcherPath, rawDispatcher, &dispatcher); err != nil {
+			return nil, err
+		}
+		if dispatcher.Offers == nil {
+			return nil, &ResponseDecodeError{Path: dispatcherPath + ".offers", Err: errMissingField}
+		}
+
+		dispatcher.SimulateDispatcher.Offers = make([]Offer, len(*dispatcher.Offers))
+		for j, rawOffer := range *dispatcher.Offers {
+			offer, err := decodeOffer(fmt.Sprintf("%s.offers[%d]", dispatcherPath, j), rawOffer)
+			if err != nil {
+				return nil, err
+			}
+			dispatcher.SimulateDispatcher.Offers[j] = offer
+		}
+
+		response.Dispatchers[i] = dispatcher.SimulateDispatcher
+	}
+
+	return response, nil
+}
+
+func decodeOffer(path string, data []byte) (Offer, error) {
+	var fields map[string]json.RawMessage
+	if err := decodeAt(path, data, &fields); err != nil {
+		return Offer{}, err
+	}
+	for _, field := range offerRequiredFields {
+		if value, ok := fields[field]; !ok || string(value) == "null" {
+			return Offer{}, &ResponseDecodeError{Path: path + "." + field, Err: errMissingField}
+		}
+	}
+	if err := decodeMoneyFields(path, fields, offerMoneyFields); err != nil {
+		return Offer{}, err
+	}
+	if value, ok := fields["composition"]; ok && string(value) != "null" {
+		var composition map[string]json.RawMessage
+		if err := decodeAt(path+".composition", value, &composition); err != nil {
+			return Offer{}, err
+		}
+		if err := decodeMoneyFields(path+".composition", composition, compositionMoneyFields); err != nil {
+			return Offer{}, err
+		}
+	}
+
+	var offer Offer
+	if err := decodeAt(path, data, &offer); err != nil {
+		return Offer{}, err
+	}
+	if offer.Carrier.Name == "" {
+		return Offer{}, &ResponseDecodeError{Path: path + ".carrier.name", Err: errMissingField}
+	}
+	if _, ok := offer.DeliveryTime.Deadline(); !ok {
+		return Offer{}, &ResponseDecodeError{Path: path + ".delivery_time", Err: errMissingDeliveryTime}
+	}
+	if _, err := offer.DeliveryTime.EstimatedDeliveryDate(); err != nil {
+		return Offer{}, &ResponseDecodeError{Path: path + ".delivery_time.estimated_date", Err: err}
+	}
+	if _, err := offer.ExpiresAt(); err != nil {
+		return Offer{}, &ResponseDecodeError{Path: path + ".expiration", Err: err}
+	}
+
+	return offer, nil
+}
+
+// decodeMoneyFields decodes the amounts of the given fields of an object on their own, so
+// that their errors carry their path
+func decodeMoneyFields(path string, fields map[string]json.RawMessage, moneyFields []string) error {
+	for _, field := range moneyFields {
+		if value, ok := fields[field]; ok {
+			var amount domain.Money
+			if err := decodeAt(path+"."+field, value, &amount); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonFields returns the JSON names of the fields of the struct type t
+func jsonFields(t reflect.Type) []string {
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// decodeAt unmarshals data into v, reporting type errors with their full JSON path
+func decodeAt(path string, data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		fieldPath := path
+		if typeErr.Field != "" {
+			fieldPath = joinPath(path, typeErr.Field)
+		}
+		return &ResponseDecodeError{
+			Path: fieldPath,
+			Err:  fmt.Errorf("expected %s, got %s", jsonKind(typeErr.Type), typeErr.Value),
+		}
+	}
+
+	return &ResponseDecodeError{Path: path, Err: err}
+}
+
+// jsonKind describes the JSON value expected for a Go type
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(domain.Money(0)) {
+		return "number"
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return t.String()
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// Carriers flattens the offers of every dispatcher into domain carriers
+func (r *SimulateResponse) Carriers() []domain.Carrier {
+	carriers := []domain.Carrier{}
+	for _, dispatcher := range r.Dispatchers {
+		for _, offer := range dispatcher.Offers {
+			// the dates were validated by DecodeSimulateResponse
+			deadline, _ := offer.DeliveryTime.Deadline()
+			estimatedDeliveryDate, _ := offer.DeliveryTime.EstimatedDeliveryDate()
+			expiresAt, _ := offer.ExpiresAt()
+
+			carriers = append(carriers, domain.Carrier{
+				Name:                    offer.Carrier.Name,
+				Service:                 offer.Service,
+				Deadline:                deadline,
+				Price:                   offer.FinalPrice,
+				Currency:                domain.CurrencyBRL,
+				CarrierRegisteredNumber: offer.Carrier.RegisteredNumber,
+				CarrierReference:        offer.Carrier.Reference,
+				DispatcherID:            dispatcher.ID,
+				OfferID:                 offer.Offer,
+				CostPrice:               offer.CostPrice,
+				CubicWeight:             offer.Weights.Cubed,
+				ExpiresAt:               expiresAt,
+				EstimatedDeliveryDate:   estimatedDeliveryDate,
+			})
+		}
+	}
+	return carriers
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeSimulateResponse_Success(t *testing.T) {
+	body := `{
+		"dispatchers": [{
+			"id": "6669d7b7e0b7fb0c7fbef2b8",
+			"request_id": "6669d7b7e0b7fb0c7fbef2b7",
+			"registered_number_shipper": "25438296000158",
+			"registered_number_dispatcher": "25438296000158",
+			"zipcode_origin": 29161376,
+			"offers": [{
+				"offer": 1,
+				"table_reference": "65f0c6e1d1f0e9d5a1b2c3d4",
+				"simulation_type": 0,
+				"carrier": {
+					"name": "CORREIOS",
+					"registered_number": "34028316000103",
+					"state_inscription": "ISENTO",
+					"reference": 281,
+					"company_name": "EMPRESA BRASILEIRA DE CORREIOS E TELEGRAFOS"
+				},
+				"service": "SEDEX",
+				"service_code": "03220",
+				"delivery_time": {"days": 2, "estimated_date": "2024-06-14"},
+				"original_delivery_time": {"days": 1, "estimated_date": "2024-06-13"},
+				"expiration": "2024-07-12T17:20:23.749Z",
+				"cost_price": 18.67,
+				"final_price": 20.99,
+				"weights": {"real": 5, "cubed": 1.6, "used": 5},
+				"composition": {"freight_weight": 15.5, "ad_valorem": 3.49, "gris": 2},
+				"home_delivery": true
+			}]
+		}]
+	}`
+
+	response, err := DecodeSimulateResponse([]byte(body))
+
+	assert.NoError(t, err)
+	assert.Len(t, response.Dispatchers, 1)
+	assert.Equal(t, 29161376, response.Dispatchers[0].ZipcodeOrigin)
+
+	offer := response.Dispatchers[0].Offers[0]
+	assert.Equal(t, "34028316000103", offer.Carrier.RegisteredNumber)
+	assert.Equal(t, 281, offer.Carrier.Reference)
+	assert.Equal(t, domain.Money(1867), offer.CostPrice)
+	assert.Equal(t, Weights{Real: 5, Cubed: 1.6, Used: 5}, offer.Weights)
+	assert.Equal(t, domain.Money(349), offer.Composition.AdValorem)
+	assert.Equal(t, "2024-06-13", offer.OriginalDeliveryTime.EstimatedDate)
+
+	estimatedDeliveryDate := time.Date(2024, 6, 14, 0, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2024, 7, 12, 17, 20, 23, 749000000, time.UTC)
+
+	assert.Equal(t, []domain.Carrier{
+		{
+			Name:                    "CORREIOS",
+			Service:                 "SEDEX",
+			Deadline:                2,
+			Price:                   2099,
+			Currency:                domain.CurrencyBRL,
+			CarrierRegisteredNumber: "34028316000103",
+			CarrierReference:        281,
+			DispatcherID:            "6669d7b7e0b7fb0c7fbef2b8",
+			OfferID:                 1,
+			CostPrice:               1867,
+			CubicWeight:             1.6,
+			ExpiresAt:               &expiresAt,
+			EstimatedDeliveryDate:   &estimatedDeliveryDate,
+		},
+	}, response.Carriers())
+}
+
+func TestDeliveryTime_Deadline(t *testing.T) {
+	days, hours, minutes := 2, 72, 2880
+
+	tests := []struct {
+		name         string
+		deliveryTime DeliveryTime
+		expected     int
+		expectedOk   bool
+	}{
+		{name: "days", deliveryTime: DeliveryTime{Days: &days, Hours: &hours}, expected: 2, expectedOk: true},
+		{name: "minutes", deliveryTime: DeliveryTime{Minutes: &minutes}, expected: 2, expectedOk: true},
+		{name: "hours", deliveryTime: DeliveryTime{Hours: &hours}, expected: 3, expectedOk: true},
+		{name: "missing", deliveryTime: DeliveryTime{}, expected: 0, expectedOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadline, ok := tt.deliveryTime.Deadline()
+			assert.Equal(t, tt.expected, deadline)
+			assert.Equal(t, tt.expectedOk, ok)
+		})
+	}
+}
+
+func TestDecodeSimulateResponse_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "invalid json",
+			body:    `invalid`,
+			wantErr: "invalid Frete Rápido response: invalid character 'i' looking for beginning of value",
+		},
+		{
+			name:    "missing dispatchers",
+			body:    `{}`,
+			wantErr: "invalid Frete Rápido response at dispatchers: missing required field",
+		},
+		{
+			name:    "invalid dispatchers list format",
+			body:    `{"dispatchers": "invalid"}`,
+			wantErr: "invalid Frete Rápido response at dispatchers: expected array, got string",
+		},
+		{
+			name:    "invalid dispatcher format",
+			body:    `{"dispatchers": ["invalid"]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0]: expected object, got string",
+		},
+		{
+			name:    "missing offers in dispatcher",
+			body:    `{"dispatchers": [{}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers: missing required field",
+		},
+		{
+			name:    "invalid offer format",
+			body:    `{"dispatchers": [{"offers": ["invalid"]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0]: expected object, got string",
+		},
+		{
+			name:    "missing carrier in offer",
+			body:    `{"dispatchers": [{"offers": [{"service": "Service1", "final_price": 10, "delivery_time": {"days": 2}}]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0].carrier: missing required field",
+		},
+		{
+			name:    "missing carrier name",
+			body:    `{"dispatchers": [{"offers": [{"carrier": {}, "service": "Service1", "final_price": 10, "delivery_time": {"days": 2}}]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0].carrier.name: missing required field",
+		},
+		{
+			name:    "invalid final price",
+			body:    `{"dispatchers": [{"offers": [{"carrier": {"name": "Carrier1"}, "service": "Service1", "final_price": "10", "delivery_time": {"days": 2}}]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0].final_price: expected number, got string",
+		},
+		{
+			name:    "invalid composition amount",
+			body:    `{"dispatchers": [{"offers": [{"carrier": {"name": "Carrier1"}, "service": "Service1", "final_price": 10, "delivery_time": {"days": 2}, "composition": {"gris": "2"}}]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0].composition.gris: expected number, got string",
+		},
+		{
+			name: "invalid nested field in a later offer",
+			body: `{"dispatchers": [{"offers": [
+				{"carrier": {"name": "Carrier1"}, "service": "Service1", "final_price": 10, "delivery_time": {"days": 2}},
+				{"carrier": {"name": "Carrier2"}, "service": "Service2", "final_price": 20, "delivery_time": {"days": "2"}}
+			]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[1].delivery_time.days: expected integer, got string",
+		},
+		{
+			name:    "invalid expiration",
+			body:    `{"dispatchers": [{"offers": [{"carrier": {"name": "Carrier1"}, "service": "Service1", "final_price": 10, "delivery_time": {"days": 2}, "expiration": "tomorrow"}]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0].expiration: invalid date \"tomorrow\"",
+		},
+		{
+			name:    "missing days, hours, or minutes in delivery_time",
+			body:    `{"dispatchers": [{"offers": [{"carrier": {"name": "Carrier1"}, "service": "Service1", "final_price": 10, "delivery_time": {}}]}]}`,
+			wantErr: "invalid Frete Rápido response at dispatchers[0].offers[0].delivery_time: missing days, hours, or minutes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := DecodeSimulateResponse([]byte(tt.body))
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Nil(t, response)
+		})
+	}
+}
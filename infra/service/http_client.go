@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ErrUpstreamTimeout is returned when a provider does not answer within its deadline
+var ErrUpstreamTimeout = errors.New("upstream request timed out")
+
+// NewHTTPClient creates the client used to reach the upstream APIs. connectTimeout bounds
+// establishing the connection, responseHeaderTimeout waiting for the response headers once
+// the request is written and timeout the whole exchange, including reading the body.
+func NewHTTPClient(connectTimeout, responseHeaderTimeout, timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
+
+// isTimeout reports whether err was caused by a deadline rather than a cancellation
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
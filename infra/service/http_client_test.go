@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHTTPClient_ResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(time.Second, 50*time.Millisecond, time.Second)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+
+	assert.Error(t, err)
+	assert.True(t, isTimeout(err))
+}
+
+func TestNewHTTPClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(time.Second, time.Second, 50*time.Millisecond)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	_, err := client.Do(req)
+
+	assert.Error(t, err)
+	assert.True(t, isTimeout(err))
+}
+
+func TestIsTimeout(t *testing.T) {
+	assert.True(t, isTimeout(context.DeadlineExceeded))
+	assert.False(t, isTimeout(context.Canceled))
+	assert.False(t, isTimeout(errors.New("connection refused")))
+}
@@ -1,77 +1,166 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
-	"io"
-	"net/http"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/belmadge/freteRapido/domain"
 	"github.com/belmadge/freteRapido/utils"
 )
 
-// CreateQuote creates a new quote by sending a request to the Frete Rápido API
-func CreateQuote(input domain.QuoteRequest) (*domain.QuoteResponse, error) {
-	if err := utils.ValidateQuoteInput(input); err != nil {
-		return nil, err
+// DefaultProviderTimeout is the time each provider has to answer a quote request
+const DefaultProviderTimeout = 10 * time.Second
+
+// QuoteProvider is a source of carrier offers for a quote request
+type QuoteProvider interface {
+	Name() string
+	Quote(ctx context.Context, input domain.QuoteRequest) ([]domain.Carrier, error)
+}
+
+// QuoteService creates quotes by fanning out to the configured QuoteProviders
+type QuoteService struct {
+	providers       []QuoteProvider
+	providerTimeout time.Duration
+	limits          domain.QuoteLimits
+}
+
+// NewQuoteService creates a QuoteService that asks every provider for offers,
+// giving each one at most providerTimeout to answer. Requests past limits are rejected
+// before any provider is called.
+func NewQuoteService(providerTimeout time.Duration, limits domain.QuoteLimits, providers ...QuoteProvider) *QuoteService {
+	if providerTimeout <= 0 {
+		providerTimeout = DefaultProviderTimeout
 	}
 
-	payload := map[string]interface{}{
-		"shipper": map[string]string{
-			"registered_number": input.Shipper.RegisteredNumber,
-			"token":             input.Shipper.Token,
-			"platform_code":     input.Shipper.PlatformCode,
-		},
-		"recipient": map[string]interface{}{
-			"type":    input.Recipient.Type,
-			"country": input.Recipient.Country,
-			"zipcode": input.Recipient.Zipcode,
-		},
-		"dispatchers":     input.Dispatchers,
-		"simulation_type": input.SimulationType,
+	return &QuoteService{
+		providers:       providers,
+		providerTimeout: providerTimeout,
+		limits:          limits,
 	}
+}
+
+type providerResult struct {
+	carriers []domain.Carrier
+	err      error
+}
 
-	requestBody, err := json.Marshal(payload)
-	if err != nil {
+// CreateQuote validates the input and requests the carrier offers from all providers
+// concurrently. Offers are merged in provider order; failed providers are reported in
+// the response as long as at least one provider succeeds, and otherwise in the error.
+func (s *QuoteService) CreateQuote(ctx context.Context, input domain.QuoteRequest) (*domain.QuoteResponse, error) {
+	if err := utils.ValidateQuoteInput(input, s.limits); err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", "https://sp.freterapido.com/api/v3/quote/simulate",
-		bytes.NewBuffer(requestBody))
-	if err != nil {
-		return nil, err
+	if len(s.providers) == 0 {
+		return nil, errors.New("no quote providers configured")
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	results := make([]providerResult, len(s.providers))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	var wg sync.WaitGroup
+	for i, provider := range s.providers {
+		wg.Add(1)
+		go func(i int, provider QuoteProvider) {
+			defer wg.Done()
+			carriers, err := s.quoteProvider(ctx, provider, input)
+			results[i] = providerResult{carriers: carriers, err: err}
+		}(i, provider)
+	}
+	wg.Wait()
+
+	quoteResponse := &domain.QuoteResponse{
+		Carrier: []domain.Carrier{},
 	}
 
-	defer resp.Body.Close()
+	var errs []error
+	for i, result := range results {
+		name := s.providers[i].Name()
+
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, result.err))
+			quoteResponse.Failures = append(quoteResponse.Failures, domain.ProviderFailure{
+				Provider: name,
+				Error:    result.err.Error(),
+			})
+			continue
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("failed to get quote from Frete Rápido")
+		for _, carrier := range result.carriers {
+			carrier.Provider = name
+			quoteResponse.Carrier = append(quoteResponse.Carrier, carrier)
+		}
 	}
 
-	bodyBytes, _ := io.ReadAll(resp.Body)
+	if len(errs) == len(s.providers) {
+		return nil, newProvidersError(errs)
+	}
 
-	var apiResponse map[string]interface{}
-	if err = json.NewDecoder(bytes.NewBuffer(bodyBytes)).Decode(&apiResponse); err != nil {
-		return nil, err
+	return quoteResponse, nil
+}
+
+// providersError reports that every provider failed. Its message lists every failure,
+// while it only unwraps to the one that best tells why, so that it maps to one status.
+type providersError struct {
+	errs  []error
+	cause error
+}
+
+// newProvidersError picks the cause of the failures: the first UpstreamError, or else the
+// first failure other than a timeout. Only when every provider timed out is the cause
+// ErrUpstreamTimeout.
+func newProvidersError(errs []error) *providersError {
+	providersErr := &providersError{errs: errs, cause: errs[0]}
+	for _, err := range errs {
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) {
+			providersErr.cause = err
+			return providersErr
+		}
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrUpstreamTimeout) {
+			providersErr.cause = err
+			break
+		}
 	}
+	return providersErr
+}
 
-	carriers, err := utils.ValidateCarriersFromAPIResponse(apiResponse)
-	if err != nil {
-		return nil, err
+func (e *providersError) Error() string {
+	return errors.Join(e.errs...).Error()
+}
+
+func (e *providersError) Unwrap() error {
+	return e.cause
+}
+
+// quoteProvider asks a single provider for offers, giving up once the provider timeout
+// expires even if the provider does not honor the context. Deadline errors are reported as
+// ErrUpstreamTimeout, while a cancelled parent context is returned as is.
+func (s *QuoteService) quoteProvider(parentCtx context.Context, provider QuoteProvider, input domain.QuoteRequest) ([]domain.Carrier, error) {
+	ctx, cancel := context.WithTimeout(parentCtx, s.providerTimeout)
+	defer cancel()
+
+	resultChan := make(chan providerResult, 1)
+	go func() {
+		carriers, err := provider.Quote(ctx, input)
+		resultChan <- providerResult{carriers: carriers, err: err}
+	}()
+
+	var result providerResult
+	select {
+	case result = <-resultChan:
+	case <-ctx.Done():
+		result.err = ctx.Err()
 	}
 
-	quoteResponse := &domain.QuoteResponse{
-		Carrier: carriers,
+	if result.err != nil && isTimeout(result.err) && !errors.Is(parentCtx.Err(), context.Canceled) {
+		return nil, ErrUpstreamTimeout
 	}
 
-	return quoteResponse, nil
+	return result.carriers, result.err
 }
@@ -1,271 +1,241 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/belmadge/freteRapido/domain"
-	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestCreateQuote_Success(t *testing.T) {
-	httpmock.Activate()
-	defer httpmock.DeactivateAndReset()
-
-	httpmock.RegisterResponder("POST", "https://sp.freterapido.com/api/v3/quote/simulate",
-		httpmock.NewStringResponder(200, `{
-			"dispatchers": [{
-				"offers": [{
-					"carrier": {"name": "Carrier1"},
-					"final_price": 10.0,
-					"service": "Service1",
-					"delivery_time": {"days": 2}
-				}]
-			}]
-		}`))
+type stubProvider struct {
+	name     string
+	carriers []domain.Carrier
+	err      error
+	delay    time.Duration
+}
 
-	input := domain.QuoteRequest{
-		Shipper: domain.Shipper{
-			RegisteredNumber: "123456789",
-			Token:            "token",
-			PlatformCode:     "platform",
-		},
-		Recipient: domain.Recipient{
-			Country: "BRA",
-			Zipcode: 12345678,
-		},
-		Dispatchers: []domain.Dispatcher{
-			{
-				RegisteredNumber: "123456789",
-				Zipcode:          12345678,
-				Volumes: []domain.Volume{
-					{
-						Category:      "7",
-						Amount:        1,
-						UnitaryWeight: 5,
-						UnitaryPrice:  349,
-						Height:        0.2,
-						Width:         0.2,
-						Length:        0.2,
-					},
-				},
-			},
-		},
-		SimulationType: []int{0},
-	}
+func (p *stubProvider) Name() string {
+	return p.name
+}
 
-	expectedResponse := domain.QuoteResponse{
-		Carrier: []domain.Carrier{
-			{Name: "Carrier1", Price: 10.0, Service: "Service1", Deadline: 2},
-		},
+func (p *stubProvider) Quote(ctx context.Context, input domain.QuoteRequest) ([]domain.Carrier, error) {
+	if p.delay > 0 {
+		time.Sleep(p.delay)
 	}
-
-	quoteResponse, err := CreateQuote(input)
-
-	assert.NoError(t, err)
-	assert.Equal(t, &expectedResponse, quoteResponse)
+	return p.carriers, p.err
 }
 
-func TestCreateQuote_Error(t *testing.T) {
+func TestCreateQuote_Success(t *testing.T) {
 	tests := []struct {
-		name        string
-		input       domain.QuoteRequest
-		setupMock   func()
-		expectedErr string
+		name      string
+		providers []QuoteProvider
+		expected  domain.QuoteResponse
 	}{
 		{
-			name: "validation error",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{},
+			name: "single provider",
+			providers: []QuoteProvider{
+				&stubProvider{
+					name:     "provider1",
+					carriers: []domain.Carrier{{Name: "Carrier1", Price: 1000, Service: "Service1", Deadline: 2}},
+				},
+			},
+			expected: domain.QuoteResponse{
+				Carrier: []domain.Carrier{
+					{Name: "Carrier1", Price: 1000, Service: "Service1", Deadline: 2, Provider: "provider1"},
+				},
 			},
-			setupMock:   func() {},
-			expectedErr: "shipper information is incomplete",
 		},
 		{
-			name: "http request error",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
+			name: "offers merged in provider order",
+			providers: []QuoteProvider{
+				&stubProvider{
+					name:     "provider1",
+					carriers: []domain.Carrier{{Name: "Carrier1", Price: 1000, Service: "Service1", Deadline: 2}},
+					delay:    20 * time.Millisecond,
 				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
+				&stubProvider{
+					name:     "provider2",
+					carriers: []domain.Carrier{{Name: "Carrier2", Price: 2000, Service: "Service2", Deadline: 1}},
 				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
-				},
-				SimulationType: []int{0},
 			},
-			setupMock: func() {
-				httpmock.Activate()
-				httpmock.RegisterResponder("POST", "https://sp.freterapido.com/api/v3/quote/simulate",
-					httpmock.NewErrorResponder(errors.New("http request error")))
+			expected: domain.QuoteResponse{
+				Carrier: []domain.Carrier{
+					{Name: "Carrier1", Price: 1000, Service: "Service1", Deadline: 2, Provider: "provider1"},
+					{Name: "Carrier2", Price: 2000, Service: "Service2", Deadline: 1, Provider: "provider2"},
+				},
 			},
-			expectedErr: "Post \"https://sp.freterapido.com/api/v3/quote/simulate\": http request error",
 		},
 		{
-			name: "non-200 response",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
+			name: "partial results when a provider fails",
+			providers: []QuoteProvider{
+				&stubProvider{name: "provider1", err: errors.New("provider error")},
+				&stubProvider{
+					name:     "provider2",
+					carriers: []domain.Carrier{{Name: "Carrier2", Price: 2000, Service: "Service2", Deadline: 1}},
 				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
+			},
+			expected: domain.QuoteResponse{
+				Carrier: []domain.Carrier{
+					{Name: "Carrier2", Price: 2000, Service: "Service2", Deadline: 1, Provider: "provider2"},
 				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
+				Failures: []domain.ProviderFailure{
+					{Provider: "provider1", Error: "provider error"},
 				},
-				SimulationType: []int{0},
 			},
-			setupMock: func() {
-				httpmock.Activate()
-				httpmock.RegisterResponder("POST", "https://sp.freterapido.com/api/v3/quote/simulate",
-					httpmock.NewStringResponder(500, `{}`))
-			},
-			expectedErr: "failed to get quote from Frete Rápido",
 		},
 		{
-			name: "invalid response body",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
+			name: "partial results when a provider times out",
+			providers: []QuoteProvider{
+				&stubProvider{name: "provider1", delay: time.Second},
+				&stubProvider{
+					name:     "provider2",
+					carriers: []domain.Carrier{{Name: "Carrier2", Price: 2000, Service: "Service2", Deadline: 1}},
 				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
+			},
+			expected: domain.QuoteResponse{
+				Carrier: []domain.Carrier{
+					{Name: "Carrier2", Price: 2000, Service: "Service2", Deadline: 1, Provider: "provider2"},
 				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
+				Failures: []domain.ProviderFailure{
+					{Provider: "provider1", Error: "upstream request timed out"},
 				},
-				SimulationType: []int{0},
 			},
-			setupMock: func() {
-				httpmock.Activate()
-				httpmock.RegisterResponder("POST", "https://sp.freterapido.com/api/v3/quote/simulate",
-					httpmock.NewStringResponder(200, `invalid`))
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quoteService := NewQuoteService(50*time.Millisecond, domain.DefaultQuoteLimits, tt.providers...)
+
+			quoteResponse, err := quoteService.CreateQuote(context.Background(), validQuoteRequest())
+
+			assert.NoError(t, err)
+			assert.Equal(t, &tt.expected, quoteResponse)
+		})
+	}
+}
+
+func TestCreateQuote_Error(t *testing.T) {
+	invalidInput := validQuoteRequest()
+	invalidInput.Shipper.Token = ""
+
+	tests := []struct {
+		name        string
+		input       domain.QuoteRequest
+		providers   []QuoteProvider
+		expectedErr string
+	}{
+		{
+			name:        "validation error",
+			input:       invalidInput,
+			providers:   []QuoteProvider{&stubProvider{name: "provider1"}},
+			expectedErr: "invalid request: /shipper/token is required",
+		},
+		{
+			name:        "no providers",
+			input:       validQuoteRequest(),
+			expectedErr: "no quote providers configured",
+		},
+		{
+			name:        "provider error",
+			input:       validQuoteRequest(),
+			providers:   []QuoteProvider{&stubProvider{name: "provider1", err: errors.New("provider error")}},
+			expectedErr: "provider1: provider error",
+		},
+		{
+			name:  "all providers fail",
+			input: validQuoteRequest(),
+			providers: []QuoteProvider{
+				&stubProvider{name: "provider1", err: errors.New("provider error")},
+				&stubProvider{name: "provider2", delay: time.Second},
+			},
+			expectedErr: "provider1: provider error\nprovider2: upstream request timed out",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quoteService := NewQuoteService(50*time.Millisecond, domain.DefaultQuoteLimits, tt.providers...)
+
+			quoteResponse, err := quoteService.CreateQuote(context.Background(), tt.input)
+
+			assert.Nil(t, quoteResponse)
+			assert.EqualError(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestCreateQuote_Timeout(t *testing.T) {
+	quoteService := NewQuoteService(50*time.Millisecond, domain.DefaultQuoteLimits, &stubProvider{name: "provider1", delay: time.Second})
+
+	quoteResponse, err := quoteService.CreateQuote(context.Background(), validQuoteRequest())
+
+	assert.Nil(t, quoteResponse)
+	assert.ErrorIs(t, err, ErrUpstreamTimeout)
+}
+
+func TestCreateQuote_AllProvidersFail(t *testing.T) {
+	upstreamErr := &UpstreamError{Code: ErrCodeInvalidRequest, Message: "invalid request", UpstreamStatus: 400}
+
+	tests := []struct {
+		name          string
+		providers     []QuoteProvider
+		expectedCause error
+	}{
+		{
+			name: "every provider times out",
+			providers: []QuoteProvider{
+				&stubProvider{name: "provider1", delay: time.Second},
+				&stubProvider{name: "provider2", delay: time.Second},
 			},
-			expectedErr: "invalid character 'i' looking for beginning of value",
+			expectedCause: ErrUpstreamTimeout,
 		},
 		{
-			name: "validation response error",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
-				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
-				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
-				},
-				SimulationType: []int{0},
+			name: "upstream error over a timeout",
+			providers: []QuoteProvider{
+				&stubProvider{name: "provider1", delay: time.Second},
+				&stubProvider{name: "provider2", err: ErrCircuitOpen},
+				&stubProvider{name: "provider3", err: upstreamErr},
 			},
-			setupMock: func() {
-				httpmock.Activate()
-				apiResponse := map[string]interface{}{
-					"dispatchers": []interface{}{
-						map[string]interface{}{
-							"offers": []interface{}{
-								map[string]interface{}{
-									"carrier": map[string]interface{}{
-										"name": "Carrier1",
-									},
-									"final_price": 10.0,
-									"service":     "Service1",
-									"delivery_time": map[string]interface{}{
-										"invalid_field": 1,
-									},
-								},
-							},
-						},
-					},
-				}
-				responseBody, _ := json.Marshal(apiResponse)
-				httpmock.RegisterResponder("POST", "https://sp.freterapido.com/api/v3/quote/simulate",
-					httpmock.NewStringResponder(200, string(responseBody)))
+			expectedCause: upstreamErr,
+		},
+		{
+			name: "other failure over a timeout",
+			providers: []QuoteProvider{
+				&stubProvider{name: "provider1", delay: time.Second},
+				&stubProvider{name: "provider2", err: ErrCircuitOpen},
 			},
-			expectedErr: "missing days, hours, or minutes in delivery_time",
+			expectedCause: ErrCircuitOpen,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setupMock()
-			defer httpmock.DeactivateAndReset()
+			quoteService := NewQuoteService(50*time.Millisecond, domain.DefaultQuoteLimits, tt.providers...)
 
-			quoteResponse, err := CreateQuote(tt.input)
+			quoteResponse, err := quoteService.CreateQuote(context.Background(), validQuoteRequest())
 
 			assert.Nil(t, quoteResponse)
-			assert.EqualError(t, err, tt.expectedErr)
-			httpmock.DeactivateAndReset()
+			assert.ErrorIs(t, err, tt.expectedCause)
+			if tt.expectedCause != ErrUpstreamTimeout {
+				assert.NotErrorIs(t, err, ErrUpstreamTimeout)
+			}
 		})
 	}
 }
+
+func TestCreateQuote_Canceled(t *testing.T) {
+	quoteService := NewQuoteService(time.Second, domain.DefaultQuoteLimits, &stubProvider{name: "provider1", delay: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	quoteResponse, err := quoteService.CreateQuote(ctx, validQuoteRequest())
+
+	assert.Nil(t, quoteResponse)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrUpstreamTimeout)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/belmadge/freteRapido/infra/repository/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryClient_Do(t *testing.T) {
+	tests := []struct {
+		name             string
+		responses        []*http.Response
+		errs             []error
+		expectedAttempts int
+		expectedStatus   int
+		expectedErr      string
+	}{
+		{
+			name:             "success without retry",
+			responses:        []*http.Response{newStringResponse(200, `{}`)},
+			errs:             []error{nil},
+			expectedAttempts: 1,
+			expectedStatus:   200,
+		},
+		{
+			name:             "retries 5xx until success",
+			responses:        []*http.Response{newStringResponse(502, ``), newStringResponse(503, ``), newStringResponse(200, `{}`)},
+			errs:             []error{nil, nil, nil},
+			expectedAttempts: 3,
+			expectedStatus:   200,
+		},
+		{
+			name:             "retries connection reset",
+			responses:        []*http.Response{nil, newStringResponse(200, `{}`)},
+			errs:             []error{fmt.Errorf("read: %w", syscall.ECONNRESET), nil},
+			expectedAttempts: 2,
+			expectedStatus:   200,
+		},
+		{
+			name:             "retries attempt timeouts",
+			responses:        []*http.Response{nil, newStringResponse(200, `{}`)},
+			errs:             []error{fmt.Errorf("Post: %w", os.ErrDeadlineExceeded), nil},
+			expectedAttempts: 2,
+			expectedStatus:   200,
+		},
+		{
+			name:             "gives up after max retries",
+			responses:        []*http.Response{newStringResponse(500, ``), newStringResponse(500, ``), newStringResponse(500, ``)},
+			errs:             []error{nil, nil, nil},
+			expectedAttempts: 3,
+			expectedStatus:   500,
+		},
+		{
+			name:             "does not retry client errors",
+			responses:        []*http.Response{newStringResponse(400, ``)},
+			errs:             []error{nil},
+			expectedAttempts: 1,
+			expectedStatus:   400,
+		},
+		{
+			name:             "does not retry other transport errors",
+			responses:        []*http.Response{nil},
+			errs:             []error{errors.New("no such host")},
+			expectedAttempts: 1,
+			expectedErr:      "no such host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			client := NewRetryClient(&mock.MockHTTPClient{
+				DoFunc: func(req *http.Request) (*http.Response, error) {
+					body, _ := io.ReadAll(req.Body)
+					assert.Equal(t, "payload", string(body))
+
+					resp, err := tt.responses[attempts], tt.errs[attempts]
+					attempts++
+					return resp, err
+				},
+			}, 2, time.Millisecond, 5*time.Millisecond)
+
+			req, _ := http.NewRequest(http.MethodPost, FreteRapidoSimulateURL, bytes.NewBufferString("payload"))
+			resp, err := client.Do(req)
+
+			assert.Equal(t, tt.expectedAttempts, attempts)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestRetryClient_Do_ContextDeadline(t *testing.T) {
+	attempts := 0
+	client := NewRetryClient(&mock.MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			attempts++
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		},
+	}, 2, time.Millisecond, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, FreteRapidoSimulateURL, nil)
+	_, err := client.Do(req)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryClient_Do_RetryAfter(t *testing.T) {
+	tooManyRequests := func(retryAfter string) *http.Response {
+		resp := newStringResponse(http.StatusTooManyRequests, ``)
+		resp.Header.Set("Retry-After", retryAfter)
+		return resp
+	}
+
+	t.Run("honors retry after within max delay", func(t *testing.T) {
+		attempts := 0
+		client := NewRetryClient(&mock.MockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts == 1 {
+					return tooManyRequests("0"), nil
+				}
+				return newStringResponse(200, `{}`), nil
+			},
+		}, 2, time.Millisecond, time.Second)
+
+		req, _ := http.NewRequest(http.MethodPost, FreteRapidoSimulateURL, nil)
+		resp, err := client.Do(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("returns response when retry after exceeds max delay", func(t *testing.T) {
+		attempts := 0
+		client := NewRetryClient(&mock.MockHTTPClient{
+			DoFunc: func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return tooManyRequests("120"), nil
+			},
+		}, 2, time.Millisecond, time.Second)
+
+		req, _ := http.NewRequest(http.MethodPost, FreteRapidoSimulateURL, nil)
+		resp, err := client.Do(req)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, attempts)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, delay)
+
+	delay, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), delay)
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+
+	_, ok = parseRetryAfter("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Codes of the UpstreamError returned by the providers
+const (
+	ErrCodeInvalidCredentials   = "invalid_credentials"
+	ErrCodeInvalidRequest       = "invalid_request"
+	ErrCodeUnserviceableZipcode = "unserviceable_zipcode"
+	ErrCodeUpstreamUnavailable  = "upstream_unavailable"
+)
+
+// UpstreamError is a non-200 answer from a provider API
+type UpstreamError struct {
+	Code           string
+	Message        string
+	UpstreamStatus int
+	UpstreamCode   string
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Message
+}
+
+// upstreamErrorPayload covers the shapes of the error bodies returned by Frete Rápido
+type upstreamErrorPayload struct {
+	Code    interface{} `json:"code"`
+	Message string      `json:"message"`
+	Error   string      `json:"error"`
+}
+
+// newUpstreamError builds an UpstreamError out of an error response, falling back to
+// defaultMessage when the body does not carry one
+func newUpstreamError(resp *http.Response, defaultMessage string) *UpstreamError {
+	upstreamErr := &UpstreamError{
+		Code:           upstreamErrorCode(resp.StatusCode),
+		Message:        defaultMessage,
+		UpstreamStatus: resp.StatusCode,
+	}
+
+	var payload upstreamErrorPayload
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return upstreamErr
+	}
+
+	if payload.Code != nil {
+		upstreamErr.UpstreamCode = fmt.Sprint(payload.Code)
+	}
+
+	switch {
+	case payload.Message != "":
+		upstreamErr.Message = payload.Message
+	case payload.Error != "":
+		upstreamErr.Message = payload.Error
+	}
+
+	return upstreamErr
+}
+
+func upstreamErrorCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrCodeInvalidCredentials
+	case http.StatusBadRequest:
+		return ErrCodeInvalidRequest
+	case http.StatusUnprocessableEntity:
+		return ErrCodeUnserviceableZipcode
+	default:
+		return ErrCodeUpstreamUnavailable
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewUpstreamError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		expected UpstreamError
+	}{
+		{
+			name:   "invalid token",
+			status: 401,
+			body:   `{"error": "Token inválido"}`,
+			expected: UpstreamError{
+				Code:           ErrCodeInvalidCredentials,
+				Message:        "Token inválido",
+				UpstreamStatus: 401,
+			},
+		},
+		{
+			name:   "invalid registered number with upstream code",
+			status: 400,
+			body:   `{"code": 1001, "message": "CNPJ inválido"}`,
+			expected: UpstreamError{
+				Code:           ErrCodeInvalidRequest,
+				Message:        "CNPJ inválido",
+				UpstreamStatus: 400,
+				UpstreamCode:   "1001",
+			},
+		},
+		{
+			name:   "unserviceable zipcode",
+			status: 422,
+			body:   `{"message": "CEP não atendido"}`,
+			expected: UpstreamError{
+				Code:           ErrCodeUnserviceableZipcode,
+				Message:        "CEP não atendido",
+				UpstreamStatus: 422,
+			},
+		},
+		{
+			name:   "outage without payload",
+			status: 503,
+			body:   `<html>Service Unavailable</html>`,
+			expected: UpstreamError{
+				Code:           ErrCodeUpstreamUnavailable,
+				Message:        "default message",
+				UpstreamStatus: 503,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newUpstreamError(newStringResponse(tt.status, tt.body), "default message")
+			assert.Equal(t, &tt.expected, err)
+		})
+	}
+}
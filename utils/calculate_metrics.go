@@ -1,46 +1,60 @@
 package utils
 
-import (
-	"errors"
+import "github.com/belmadge/freteRapido/domain"
 
-	"github.com/belmadge/freteRapido/domain"
-)
+// CalculateMetrics aggregates every carrier offer of the quotes, by carrier and by
+// carrier and service. The spread of the prices and deadlines is exact up to
+// ExactStatsLimit offers. Without offers the metrics are empty, with no cheapest, most
+// expensive, fastest or slowest quote.
+func CalculateMetrics(quotes []domain.Quote) domain.Metrics {
+	carrierMetrics := make(map[string]domain.CarrierMetrics)
+	var cheapestQuote, mostExpensiveQuote, fastestQuote, slowestQuote *domain.Carrier
 
-func CalculateMetrics(quotes []domain.Quote) (map[string]interface{}, error) {
-	if len(quotes) == 0 {
-		return nil, errors.New("no quotes provided")
+	offers := 0
+	for _, quote := range quotes {
+		offers += len(quote.Carrier)
 	}
-
-	carrierMetrics := make(map[string]map[string]float64)
-	var cheapestQuote, mostExpensiveQuote *domain.Carrier
+	stats := NewOfferStats(offers <= ExactStatsLimit)
 
 	for _, quote := range quotes {
 		for _, carrier := range quote.Carrier {
 			updateCarrierMetrics(carrierMetrics, carrier)
 			updateCheapestAndMostExpensiveQuote(&cheapestQuote, &mostExpensiveQuote, carrier)
+			updateFastestAndSlowestQuote(&fastestQuote, &slowestQuote, carrier)
+			stats.Add(carrier)
 		}
 	}
 
 	calculateAveragePrice(carrierMetrics)
 
-	result := map[string]interface{}{
-		"carriers":             carrierMetrics,
-		"cheapest_quote":       cheapestQuote,
-		"most_expensive_quote": mostExpensiveQuote,
+	metrics := domain.Metrics{
+		Currency:           domain.CurrencyBRL,
+		Carriers:           carrierMetrics,
+		CheapestQuote:      cheapestQuote,
+		MostExpensiveQuote: mostExpensiveQuote,
+		FastestQuote:       fastestQuote,
+		SlowestQuote:       slowestQuote,
 	}
+	stats.Apply(&metrics)
+	metrics.CostVsSpeed = CompareCostVsSpeed(metrics.Carriers)
 
-	return result, nil
+	return metrics
 }
 
-func updateCarrierMetrics(carrierMetrics map[string]map[string]float64, carrier domain.Carrier) {
-	if _, exists := carrierMetrics[carrier.Name]; !exists {
-		carrierMetrics[carrier.Name] = map[string]float64{
-			"count":       0,
-			"total_price": 0,
-		}
+func updateCarrierMetrics(carrierMetrics map[string]domain.CarrierMetrics, carrier domain.Carrier) {
+	metrics := carrierMetrics[carrier.Name]
+	metrics.Count++
+	metrics.TotalPrice += carrier.Price
+	if metrics.Services == nil {
+		metrics.Services = make(map[string]domain.ServiceMetrics)
 	}
-	carrierMetrics[carrier.Name]["count"]++
-	carrierMetrics[carrier.Name]["total_price"] += carrier.Price
+
+	service := metrics.Services[carrier.Service]
+	service.Count++
+	service.TotalPrice += carrier.Price
+	metrics.Services[carrier.Service] = service
+
+	carrierMetrics[carrier.Name] = metrics
 }
 
 func updateCheapestAndMostExpensiveQuote(cheapestQuote, mostExpensiveQuote **domain.Carrier, carrier domain.Carrier) {
@@ -52,8 +66,27 @@ func updateCheapestAndMostExpensiveQuote(cheapestQuote, mostExpensiveQuote **dom
 	}
 }
 
-func calculateAveragePrice(carrierMetrics map[string]map[string]float64) {
-	for _, metrics := range carrierMetrics {
-		metrics["average_price"] = metrics["total_price"] / metrics["count"]
+// updateFastestAndSlowestQuote breaks ties between offers with the same deadline in
+// favor of the cheaper one
+func updateFastestAndSlowestQuote(fastestQuote, slowestQuote **domain.Carrier, carrier domain.Carrier) {
+	if *fastestQuote == nil || carrier.Deadline < (*fastestQuote).Deadline ||
+		(carrier.Deadline == (*fastestQuote).Deadline && carrier.Price < (*fastestQuote).Price) {
+		*fastestQuote = &carrier
+	}
+	if *slowestQuote == nil || carrier.Deadline > (*slowestQuote).Deadline ||
+		(carrier.Deadline == (*slowestQuote).Deadline && carrier.Price < (*slowestQuote).Price) {
+		*slowestQuote = &carrier
+	}
+}
+
+// calculateAveragePrice rounds the averages half to even to the centavo
+func calculateAveragePrice(carrierMetrics map[string]domain.CarrierMetrics) {
+	for name, metrics := range carrierMetrics {
+		metrics.AveragePrice = metrics.TotalPrice.Div(metrics.Count)
+		for service, serviceMetrics := range metrics.Services {
+			serviceMetrics.AveragePrice = serviceMetrics.TotalPrice.Div(serviceMetrics.Count)
+			metrics.Services[service] = serviceMetrics
+		}
+		carrierMetrics[name] = metrics
 	}
 }
@@ -11,42 +11,131 @@ func TestCalculateMetrics_Success(t *testing.T) {
 	quotes := []domain.Quote{
 		{
 			Carrier: []domain.Carrier{
-				{Name: "Carrier1", Price: 10},
-				{Name: "Carrier2", Price: 20},
+				{Name: "Carrier1", Price: 1000},
+				{Name: "Carrier2", Price: 2000},
 			},
 		},
 		{
 			Carrier: []domain.Carrier{
-				{Name: "Carrier1", Price: 30},
-				{Name: "Carrier2", Price: 40},
+				{Name: "Carrier1", Price: 3000},
+				{Name: "Carrier2", Price: 4000},
 			},
 		},
 	}
 
-	expected := map[string]interface{}{
-		"carriers": map[string]map[string]float64{
-			"Carrier1": {"count": 2, "total_price": 40, "average_price": 20},
-			"Carrier2": {"count": 2, "total_price": 60, "average_price": 30},
+	expected := domain.Metrics{
+		Currency: domain.CurrencyBRL,
+		Carriers: map[string]domain.CarrierMetrics{
+			"Carrier1": {
+				Count: 2, TotalPrice: 4000, AveragePrice: 2000,
+				PriceStats: domain.PriceStats{Min: 1000, Max: 3000, Median: 2000, P90: 2800, P95: 2900, StdDev: 1000},
+				Services: map[string]domain.ServiceMetrics{
+					"": {
+						Count: 2, TotalPrice: 4000, AveragePrice: 2000,
+						PriceStats: domain.PriceStats{Min: 1000, Max: 3000, Median: 2000, P90: 2800, P95: 2900, StdDev: 1000},
+					},
+				},
+			},
+			"Carrier2": {
+				Count: 2, TotalPrice: 6000, AveragePrice: 3000,
+				PriceStats: domain.PriceStats{Min: 2000, Max: 4000, Median: 3000, P90: 3800, P95: 3900, StdDev: 1000},
+				Services: map[string]domain.ServiceMetrics{
+					"": {
+						Count: 2, TotalPrice: 6000, AveragePrice: 3000,
+						PriceStats: domain.PriceStats{Min: 2000, Max: 4000, Median: 3000, P90: 3800, P95: 3900, StdDev: 1000},
+					},
+				},
+			},
+		},
+		CheapestQuote:      &domain.Carrier{Name: "Carrier1", Price: 1000},
+		MostExpensiveQuote: &domain.Carrier{Name: "Carrier2", Price: 4000},
+		FastestQuote:       &domain.Carrier{Name: "Carrier1", Price: 1000},
+		SlowestQuote:       &domain.Carrier{Name: "Carrier1", Price: 1000},
+		CostVsSpeed: &domain.CostVsSpeed{
+			Baseline: domain.ServiceSummary{Carrier: "Carrier1", AveragePrice: 2000},
+			Services: []domain.SpeedPremium{},
 		},
-		"cheapest_quote":       &domain.Carrier{Name: "Carrier1", Price: 10},
-		"most_expensive_quote": &domain.Carrier{Name: "Carrier2", Price: 40},
 	}
 
-	result, err := CalculateMetrics(quotes)
+	result := CalculateMetrics(quotes)
+
+	assert.Equal(t, expected, result)
+}
+
+func TestCalculateMetrics_ExactCents(t *testing.T) {
+	quotes := []domain.Quote{
+		{Carrier: []domain.Carrier{{Name: "Carrier1", Price: 10}, {Name: "Carrier2", Price: 1000}}},
+		{Carrier: []domain.Carrier{{Name: "Carrier1", Price: 20}, {Name: "Carrier2", Price: 1001}}},
+		{Carrier: []domain.Carrier{{Name: "Carrier2", Price: 1001}}},
+	}
+
+	result := CalculateMetrics(quotes)
+
+	carrier1 := result.Carriers["Carrier1"]
+	assert.Equal(t, []interface{}{2, domain.Money(30), domain.Money(15)}, []interface{}{carrier1.Count, carrier1.TotalPrice, carrier1.AveragePrice})
+	// 30.02 / 3 = 10.0066... is rounded to the centavo
+	carrier2 := result.Carriers["Carrier2"]
+	assert.Equal(t, []interface{}{3, domain.Money(3002), domain.Money(1001)}, []interface{}{carrier2.Count, carrier2.TotalPrice, carrier2.AveragePrice})
+	// the standard deviation of 10.00, 10.01 and 10.01 is 0.0047... centavos
+	assert.Equal(t, domain.Money(0), carrier2.PriceStats.StdDev)
+}
+
+func TestCalculateMetrics_Stats(t *testing.T) {
+	var quotes []domain.Quote
+	for i, price := range []domain.Money{1000, 1200, 1500, 1500, 9000} {
+		quotes = append(quotes, domain.Quote{Carrier: []domain.Carrier{
+			{Name: "Correios", Service: "PAC", Price: price, Deadline: 5 + i},
+			{Name: "Correios", Service: "SEDEX", Price: 2 * price, Deadline: 1 + i%2},
+		}})
+	}
+
+	result := CalculateMetrics(quotes)
+
+	assert.False(t, result.Approximate)
+	correios := result.Carriers["Correios"]
+	assert.Equal(t, domain.PriceStats{Min: 1000, Max: 18000, Median: 2200, P90: 9900, P95: 13950, StdDev: 5082}, correios.PriceStats)
+	assert.Equal(t, domain.DeadlineStats{Min: 1, Max: 9, Median: 3.5, P90: 8.1, P95: 8.55, StdDev: 2.99}, correios.DeadlineStats)
 
-	assert.Nil(t, err)
-	assert.Equal(t, expected["carriers"], result["carriers"])
-	assert.Equal(t, expected["cheapest_quote"].(*domain.Carrier).Name, result["cheapest_quote"].(*domain.Carrier).Name)
-	assert.Equal(t, expected["cheapest_quote"].(*domain.Carrier).Price, result["cheapest_quote"].(*domain.Carrier).Price)
-	assert.Equal(t, expected["most_expensive_quote"].(*domain.Carrier).Name, result["most_expensive_quote"].(*domain.Carrier).Name)
-	assert.Equal(t, expected["most_expensive_quote"].(*domain.Carrier).Price, result["most_expensive_quote"].(*domain.Carrier).Price)
+	pac := correios.Services["PAC"]
+	assert.Equal(t, 5, pac.Count)
+	assert.Equal(t, domain.Money(14200), pac.TotalPrice)
+	assert.Equal(t, domain.Money(2840), pac.AveragePrice)
+	// the median of an odd number of prices is the middle one, and the percentiles are
+	// interpolated between the closest prices: 1500 + 0.6 * (9000 - 1500) = 6000
+	assert.Equal(t, domain.PriceStats{Min: 1000, Max: 9000, Median: 1500, P90: 6000, P95: 7500, StdDev: 3086}, pac.PriceStats)
+	assert.Equal(t, domain.DeadlineStats{Min: 5, Max: 9, Median: 7, P90: 8.6, P95: 8.8, StdDev: 1.41}, pac.DeadlineStats)
+	assert.Equal(t, domain.DeadlineStats{Min: 1, Max: 2, Median: 1, P90: 2, P95: 2, StdDev: 0.49}, correios.Services["SEDEX"].DeadlineStats)
 }
 
-func TestCalculateMetrics_Error(t *testing.T) {
-	quotes := []domain.Quote{}
+func TestCalculateMetrics_NoQuotes(t *testing.T) {
+	result := CalculateMetrics(nil)
+
+	assert.Equal(t, domain.Metrics{Currency: domain.CurrencyBRL, Carriers: map[string]domain.CarrierMetrics{}}, result)
+}
+
+func TestCalculateMetrics_Deadlines(t *testing.T) {
+	quotes := []domain.Quote{
+		{Carrier: []domain.Carrier{
+			{Name: "Correios", Service: "PAC", Price: 2000, Deadline: 8},
+			{Name: "Correios", Service: "SEDEX", Price: 4500, Deadline: 2},
+			{Name: "Jadlog", Service: ".Package", Price: 2500, Deadline: 5},
+		}},
+		{Carrier: []domain.Carrier{
+			{Name: "Correios", Service: "PAC", Price: 1800, Deadline: 6},
+			{Name: "Correios", Service: "SEDEX", Price: 3500, Deadline: 1},
+			{Name: "Jadlog", Service: ".Package", Price: 2100, Deadline: 1},
+		}},
+	}
 
-	_, err := CalculateMetrics(quotes)
+	result := CalculateMetrics(quotes)
 
-	assert.NotNil(t, err)
-	assert.Equal(t, "no quotes provided", err.Error())
+	// the offers due in a day tie, so the cheaper one is the fastest
+	assert.Equal(t, &domain.Carrier{Name: "Jadlog", Service: ".Package", Price: 2100, Deadline: 1}, result.FastestQuote)
+	assert.Equal(t, &domain.Carrier{Name: "Correios", Service: "PAC", Price: 2000, Deadline: 8}, result.SlowestQuote)
+	assert.Equal(t, 4.25, result.Carriers["Correios"].AverageDeadline)
+	assert.Equal(t, 1.5, result.Carriers["Correios"].Services["SEDEX"].AverageDeadline)
+	assert.Equal(t, 3.0, result.Carriers["Jadlog"].AverageDeadline)
+	assert.NotNil(t, result.CostVsSpeed)
+	assert.Equal(t, domain.ServiceSummary{Carrier: "Correios", Service: "PAC", AveragePrice: 1900, AverageDeadline: 7}, result.CostVsSpeed.Baseline)
+	assert.Len(t, result.CostVsSpeed.Services, 2)
 }
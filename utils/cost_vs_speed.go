@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"sort"
+
+	"github.com/belmadge/freteRapido/domain"
+)
+
+// CompareCostVsSpeed compares every service faster than the cheapest one, on average,
+// with it. The baseline is the service with the lowest average price, the fastest
+// among those that tie. Without services there is nothing to compare and it is nil.
+func CompareCostVsSpeed(carriers map[string]domain.CarrierMetrics) *domain.CostVsSpeed {
+	var services []domain.ServiceSummary
+	for name, carrier := range carriers {
+		for service, metrics := range carrier.Services {
+			services = append(services, domain.ServiceSummary{
+				Carrier:         name,
+				Service:         service,
+				AveragePrice:    metrics.AveragePrice,
+				AverageDeadline: metrics.AverageDeadline,
+			})
+		}
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		a, b := services[i], services[j]
+		if a.AveragePrice != b.AveragePrice {
+			return a.AveragePrice < b.AveragePrice
+		}
+		if a.AverageDeadline != b.AverageDeadline {
+			return a.AverageDeadline < b.AverageDeadline
+		}
+		if a.Carrier != b.Carrier {
+			return a.Carrier < b.Carrier
+		}
+		return a.Service < b.Service
+	})
+
+	comparison := &domain.CostVsSpeed{Baseline: services[0], Services: []domain.SpeedPremium{}}
+	for _, service := range services[1:] {
+		daysSaved := roundHundredths(comparison.Baseline.AverageDeadline - service.AverageDeadline)
+		if daysSaved <= 0 {
+			continue
+		}
+
+		extraPrice := service.AveragePrice - comparison.Baseline.AveragePrice
+		comparison.Services = append(comparison.Services, domain.SpeedPremium{
+			ServiceSummary:   service,
+			ExtraPrice:       extraPrice,
+			DaysSaved:        daysSaved,
+			PricePerDaySaved: roundCents(float64(extraPrice) / daysSaved),
+		})
+	}
+
+	sort.SliceStable(comparison.Services, func(i, j int) bool {
+		return comparison.Services[i].PricePerDaySaved < comparison.Services[j].PricePerDaySaved
+	})
+	return comparison
+}
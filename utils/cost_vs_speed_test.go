@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/belmadge/freteRapido/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareCostVsSpeed(t *testing.T) {
+	carriers := map[string]domain.CarrierMetrics{
+		"Correios": {Services: map[string]domain.ServiceMetrics{
+			"PAC":   {AveragePrice: 1900, AverageDeadline: 7},
+			"SEDEX": {AveragePrice: 4000, AverageDeadline: 1.5},
+		}},
+		"Jadlog": {Services: map[string]domain.ServiceMetrics{
+			".Package":  {AveragePrice: 2300, AverageDeadline: 3},
+			"Econômico": {AveragePrice: 2100, AverageDeadline: 9},
+		}},
+	}
+
+	comparison := CompareCostVsSpeed(carriers)
+
+	assert.Equal(t, &domain.CostVsSpeed{
+		Baseline: domain.ServiceSummary{Carrier: "Correios", Service: "PAC", AveragePrice: 1900, AverageDeadline: 7},
+		Services: []domain.SpeedPremium{
+			{
+				ServiceSummary:   domain.ServiceSummary{Carrier: "Jadlog", Service: ".Package", AveragePrice: 2300, AverageDeadline: 3},
+				ExtraPrice:       400,
+				DaysSaved:        4,
+				PricePerDaySaved: 100,
+			},
+			{
+				// 21.00 / 5.5 = 3.8181... is rounded to the centavo
+				ServiceSummary:   domain.ServiceSummary{Carrier: "Correios", Service: "SEDEX", AveragePrice: 4000, AverageDeadline: 1.5},
+				ExtraPrice:       2100,
+				DaysSaved:        5.5,
+				PricePerDaySaved: 382,
+			},
+		},
+	}, comparison)
+}
+
+func TestCompareCostVsSpeed_Ties(t *testing.T) {
+	carriers := map[string]domain.CarrierMetrics{
+		"Correios": {Services: map[string]domain.ServiceMetrics{
+			"PAC": {AveragePrice: 1900, AverageDeadline: 7},
+		}},
+		"Jadlog": {Services: map[string]domain.ServiceMetrics{
+			".Package": {AveragePrice: 1900, AverageDeadline: 4},
+		}},
+	}
+
+	comparison := CompareCostVsSpeed(carriers)
+
+	// as cheap and faster, the Jadlog service is the baseline and nothing is faster
+	assert.Equal(t, domain.ServiceSummary{Carrier: "Jadlog", Service: ".Package", AveragePrice: 1900, AverageDeadline: 4}, comparison.Baseline)
+	assert.Empty(t, comparison.Services)
+}
+
+func TestCompareCostVsSpeed_NoServices(t *testing.T) {
+	assert.Nil(t, CompareCostVsSpeed(map[string]domain.CarrierMetrics{}))
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// ExactStatsLimit is the largest number of offers whose price and deadline spread is
+// calculated exactly. Past it the values are not kept and the percentiles are
+// estimated with a t-digest.
+const ExactStatsLimit = 10_000
+
+// Distribution accumulates values to describe their spread. An exact distribution keeps
+// every value; any other summarizes them in a t-digest.
+type Distribution struct {
+	values []float64
+	sorted bool
+	digest *TDigest
+}
+
+// NewDistribution creates an empty distribution, exact or estimated with a t-digest
+func NewDistribution(exact bool) *Distribution {
+	if exact {
+		return &Distribution{}
+	}
+	return &Distribution{digest: NewTDigest(DefaultTDigestCompression)}
+}
+
+// Exact reports whether the distribution keeps every value
+func (d *Distribution) Exact() bool {
+	return d.digest == nil
+}
+
+// Add adds a value to the distribution
+func (d *Distribution) Add(x float64) {
+	if d.digest != nil {
+		d.digest.Add(x)
+		return
+	}
+	d.values = append(d.values, x)
+	d.sorted = false
+}
+
+// AddN adds n times the same value to the distribution
+func (d *Distribution) AddN(x float64, n int) {
+	if d.digest != nil {
+		d.digest.AddN(x, n)
+		return
+	}
+	for i := 0; i < n; i++ {
+		d.values = append(d.values, x)
+	}
+	d.sorted = false
+}
+
+// Merge adds the values summarized by digest, after which the distribution is no
+// longer exact
+func (d *Distribution) Merge(digest *TDigest) {
+	if d.digest == nil {
+		d.digest = NewTDigest(DefaultTDigestCompression)
+		for _, x := range d.values {
+			d.digest.Add(x)
+		}
+		d.values = nil
+	}
+	d.digest.Merge(digest)
+}
+
+// Count returns the number of values added
+func (d *Distribution) Count() int {
+	if d.digest != nil {
+		return d.digest.Count()
+	}
+	return len(d.values)
+}
+
+// Min returns the smallest value, or zero without values
+func (d *Distribution) Min() float64 {
+	if d.Count() == 0 {
+		return 0
+	}
+	if d.digest != nil {
+		return d.digest.Min()
+	}
+	return d.sortedValues()[0]
+}
+
+// Max returns the largest value, or zero without values
+func (d *Distribution) Max() float64 {
+	if d.Count() == 0 {
+		return 0
+	}
+	if d.digest != nil {
+		return d.digest.Max()
+	}
+	return d.sortedValues()[len(d.values)-1]
+}
+
+// Quantile returns the value below which the fraction q of the values fall, or zero
+// without values. Exact distributions interpolate linearly between the closest ranks,
+// so the median of an even number of values is the mean of the middle two.
+func (d *Distribution) Quantile(q float64) float64 {
+	if d.Count() == 0 {
+		return 0
+	}
+	if d.digest != nil {
+		return d.digest.Quantile(q)
+	}
+
+	values := d.sortedValues()
+	rank := q * float64(len(values)-1)
+	below := int(math.Floor(rank))
+	if below+1 >= len(values) {
+		return values[len(values)-1]
+	}
+	return values[below] + (values[below+1]-values[below])*(rank-float64(below))
+}
+
+// Mean returns the mean of the values, or zero without values
+func (d *Distribution) Mean() float64 {
+	if d.Count() == 0 {
+		return 0
+	}
+	if d.digest != nil {
+		return d.digest.Mean()
+	}
+
+	// summing the sorted values makes the result independent of the order they were
+	// added in
+	var sum float64
+	for _, x := range d.sortedValues() {
+		sum += x
+	}
+	return sum / float64(len(d.values))
+}
+
+// StdDev returns the standard deviation of the population of the values, or zero
+// without values
+func (d *Distribution) StdDev() float64 {
+	if d.Count() == 0 {
+		return 0
+	}
+	if d.digest != nil {
+		return d.digest.StdDev()
+	}
+
+	values := d.sortedValues()
+	mean := d.Mean()
+	var squares float64
+	for _, x := range values {
+		squares += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(squares / float64(len(values)))
+}
+
+func (d *Distribution) sortedValues() []float64 {
+	if !d.sorted {
+		sort.Float64s(d.values)
+		d.sorted = true
+	}
+	return d.values
+}
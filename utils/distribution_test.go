@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistribution_Exact(t *testing.T) {
+	distribution := NewDistribution(true)
+	for _, x := range []float64{7, 1, 3, 5} {
+		distribution.Add(x)
+	}
+
+	assert.True(t, distribution.Exact())
+	assert.Equal(t, 4, distribution.Count())
+	assert.Equal(t, 1.0, distribution.Min())
+	assert.Equal(t, 7.0, distribution.Max())
+	assert.Equal(t, 4.0, distribution.Quantile(0.5))
+	assert.InDelta(t, 6.4, distribution.Quantile(0.9), 1e-9)
+	assert.Equal(t, 7.0, distribution.Quantile(1))
+	assert.InDelta(t, 2.2360679, distribution.StdDev(), 1e-6)
+}
+
+func TestDistribution_AddN(t *testing.T) {
+	distribution := NewDistribution(true)
+	distribution.AddN(7, 2)
+	distribution.AddN(1, 1)
+	distribution.AddN(3, 0)
+
+	assert.Equal(t, 3, distribution.Count())
+	assert.Equal(t, 1.0, distribution.Min())
+	assert.Equal(t, 7.0, distribution.Quantile(0.5))
+	assert.Equal(t, 7.0, distribution.Max())
+}
+
+func TestDistribution_Empty(t *testing.T) {
+	for _, exact := range []bool{true, false} {
+		distribution := NewDistribution(exact)
+
+		assert.Zero(t, distribution.Count())
+		assert.Zero(t, distribution.Min())
+		assert.Zero(t, distribution.Max())
+		assert.Zero(t, distribution.Quantile(0.5))
+		assert.Zero(t, distribution.StdDev())
+	}
+}
+
+func TestDistribution_Merge(t *testing.T) {
+	distribution := NewDistribution(true)
+	distribution.Add(1)
+	distribution.Add(2)
+	digest := NewTDigest(DefaultTDigestCompression)
+	digest.Add(3)
+	digest.Add(10)
+
+	distribution.Merge(digest)
+
+	assert.False(t, distribution.Exact())
+	assert.Equal(t, 4, distribution.Count())
+	assert.Equal(t, 1.0, distribution.Min())
+	assert.Equal(t, 10.0, distribution.Max())
+	assert.Equal(t, 2.5, distribution.Quantile(0.5))
+}
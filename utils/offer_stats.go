@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/belmadge/freteRapido/domain"
+)
+
+// OfferStats accumulates the prices and deadlines of offers by carrier and by carrier
+// and service, to describe their spread in the metrics
+type OfferStats struct {
+	exact    bool
+	carriers map[string]*offerDistributions
+	services map[carrierService]*offerDistributions
+}
+
+type carrierService struct {
+	carrier string
+	service string
+}
+
+// offerDistributions are the distributions of the prices, in centavos, and the
+// deadlines, in days, of a set of offers
+type offerDistributions struct {
+	price    *Distribution
+	deadline *Distribution
+}
+
+// NewOfferStats creates empty stats, exact or estimated with t-digests
+func NewOfferStats(exact bool) *OfferStats {
+	return &OfferStats{
+		exact:    exact,
+		carriers: map[string]*offerDistributions{},
+		services: map[carrierService]*offerDistributions{},
+	}
+}
+
+// Exact reports whether the stats keep every price and deadline
+func (s *OfferStats) Exact() bool {
+	return s.exact
+}
+
+// Add adds the price and deadline of an offer
+func (s *OfferStats) Add(carrier domain.Carrier) {
+	for _, distributions := range s.distributions(carrier.Name, carrier.Service) {
+		distributions.price.Add(float64(carrier.Price))
+		distributions.deadline.Add(float64(carrier.Deadline))
+	}
+}
+
+// AddPrices adds the price of n offers of the given carrier and service
+func (s *OfferStats) AddPrices(name, service string, price domain.Money, n int) {
+	for _, distributions := range s.distributions(name, service) {
+		distributions.price.AddN(float64(price), n)
+	}
+}
+
+// AddDeadlines adds the deadline of n offers of the given carrier and service
+func (s *OfferStats) AddDeadlines(name, service string, deadline, n int) {
+	for _, distributions := range s.distributions(name, service) {
+		distributions.deadline.AddN(float64(deadline), n)
+	}
+}
+
+// Merge adds the prices and deadlines summarized by the digests of offers of the given
+// carrier and service. The stats are no longer exact.
+func (s *OfferStats) Merge(name, service string, price, deadline *TDigest) {
+	s.exact = false
+	for _, distributions := range s.distributions(name, service) {
+		distributions.price.Merge(price)
+		distributions.deadline.Merge(deadline)
+	}
+}
+
+// Apply fills the average deadline and the price and deadline stats of the carriers and
+// services of metrics
+func (s *OfferStats) Apply(metrics *domain.Metrics) {
+	metrics.Approximate = !s.exact
+	for name, carrierMetrics := range metrics.Carriers {
+		if distributions, ok := s.carriers[name]; ok {
+			carrierMetrics.AverageDeadline = roundHundredths(distributions.deadline.Mean())
+			carrierMetrics.PriceStats = distributions.priceStats()
+			carrierMetrics.DeadlineStats = distributions.deadlineStats()
+		}
+		for service, serviceMetrics := range carrierMetrics.Services {
+			if distributions, ok := s.services[carrierService{carrier: name, service: service}]; ok {
+				serviceMetrics.AverageDeadline = roundHundredths(distributions.deadline.Mean())
+				serviceMetrics.PriceStats = distributions.priceStats()
+				serviceMetrics.DeadlineStats = distributions.deadlineStats()
+				carrierMetrics.Services[service] = serviceMetrics
+			}
+		}
+		metrics.Carriers[name] = carrierMetrics
+	}
+}
+
+// distributions returns the distributions of the carrier and of its service, creating
+// them on first use
+func (s *OfferStats) distributions(name, service string) []*offerDistributions {
+	carrier, ok := s.carriers[name]
+	if !ok {
+		carrier = s.newDistributions()
+		s.carriers[name] = carrier
+	}
+
+	key := carrierService{carrier: name, service: service}
+	byService, ok := s.services[key]
+	if !ok {
+		byService = s.newDistributions()
+		s.services[key] = byService
+	}
+
+	return []*offerDistributions{carrier, byService}
+}
+
+func (s *OfferStats) newDistributions() *offerDistributions {
+	return &offerDistributions{price: NewDistribution(s.exact), deadline: NewDistribution(s.exact)}
+}
+
+func (d *offerDistributions) priceStats() domain.PriceStats {
+	return domain.PriceStats{
+		Min:    roundCents(d.price.Min()),
+		Max:    roundCents(d.price.Max()),
+		Median: roundCents(d.price.Quantile(0.5)),
+		P90:    roundCents(d.price.Quantile(0.9)),
+		P95:    roundCents(d.price.Quantile(0.95)),
+		StdDev: roundCents(d.price.StdDev()),
+	}
+}
+
+func (d *offerDistributions) deadlineStats() domain.DeadlineStats {
+	return domain.DeadlineStats{
+		Min:    int(d.deadline.Min()),
+		Max:    int(d.deadline.Max()),
+		Median: roundHundredths(d.deadline.Quantile(0.5)),
+		P90:    roundHundredths(d.deadline.Quantile(0.9)),
+		P95:    roundHundredths(d.deadline.Quantile(0.95)),
+		StdDev: roundHundredths(d.deadline.StdDev()),
+	}
+}
+
+// roundCents rounds an amount of centavos half to even
+func roundCents(cents float64) domain.Money {
+	return domain.Money(math.RoundToEven(cents))
+}
+
+func roundHundredths(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,254 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// DefaultTDigestCompression bounds a t-digest to about a hundred centroids, which keeps
+// the estimated median, p90 and p95 within a fraction of a percent of the exact ones
+const DefaultTDigestCompression = 200
+
+// tdigestBufferFactor is how many times the compression values are buffered before
+// being merged into the centroids
+const tdigestBufferFactor = 5
+
+// TDigest is a merging t-digest: a sketch of a distribution that estimates its
+// percentiles from a bounded number of centroids, which are smaller towards the tails.
+// Digests of parts of the values can be merged into a digest of all of them. The
+// count, sum, sum of squares, min and max are kept exactly.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	sum         float64
+	sumSquares  float64
+	min         float64
+	max         float64
+}
+
+// centroid is the mean of count values of a t-digest
+type centroid struct {
+	mean  float64
+	count float64
+}
+
+// NewTDigest creates an empty t-digest with the given compression
+func NewTDigest(compression float64) *TDigest {
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add adds a value to the digest
+func (t *TDigest) Add(x float64) {
+	t.AddN(x, 1)
+}
+
+// AddN adds n times the same value to the digest
+func (t *TDigest) AddN(x float64, n int) {
+	if n <= 0 {
+		return
+	}
+
+	count := float64(n)
+	t.count += count
+	t.sum += x * count
+	t.sumSquares += x * x * count
+	t.min = math.Min(t.min, x)
+	t.max = math.Max(t.max, x)
+	t.buffer = append(t.buffer, centroid{mean: x, count: count})
+	t.compressIfFull()
+}
+
+// Merge adds the values of other to the digest
+func (t *TDigest) Merge(other *TDigest) {
+	if other == nil || other.count == 0 {
+		return
+	}
+
+	t.count += other.count
+	t.sum += other.sum
+	t.sumSquares += other.sumSquares
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+	for _, centroids := range [][]centroid{other.centroids, other.buffer} {
+		for _, c := range centroids {
+			t.buffer = append(t.buffer, c)
+			t.compressIfFull()
+		}
+	}
+}
+
+// Count returns the number of values added
+func (t *TDigest) Count() int {
+	return int(t.count)
+}
+
+// Min returns the smallest value added
+func (t *TDigest) Min() float64 {
+	return t.min
+}
+
+// Max returns the largest value added
+func (t *TDigest) Max() float64 {
+	return t.max
+}
+
+// Mean returns the mean of the values added
+func (t *TDigest) Mean() float64 {
+	if t.count == 0 {
+		return 0
+	}
+	return t.sum / t.count
+}
+
+// StdDev returns the standard deviation of the population of the values added
+func (t *TDigest) StdDev() float64 {
+	if t.count == 0 {
+		return 0
+	}
+	mean := t.sum / t.count
+	return math.Sqrt(math.Max(0, t.sumSquares/t.count-mean*mean))
+}
+
+// Quantile estimates the value below which the fraction q of the values fall, by
+// interpolating between the centers of the centroids around it
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return 0
+	}
+
+	index := q * t.count
+	first := t.centroids[0]
+	if index <= first.count/2 {
+		return t.min + (first.mean-t.min)*index/(first.count/2)
+	}
+
+	center := first.count / 2
+	for i := 0; i < len(t.centroids)-1; i++ {
+		step := (t.centroids[i].count + t.centroids[i+1].count) / 2
+		if index <= center+step {
+			return t.centroids[i].mean + (t.centroids[i+1].mean-t.centroids[i].mean)*(index-center)/step
+		}
+		center += step
+	}
+
+	last := t.centroids[len(t.centroids)-1]
+	rest := t.count - center
+	if rest <= 0 {
+		return t.max
+	}
+	return math.Min(t.max, last.mean+(t.max-last.mean)*(index-center)/rest)
+}
+
+func (t *TDigest) compressIfFull() {
+	if float64(len(t.buffer)) >= tdigestBufferFactor*t.compression {
+		t.compress()
+	}
+}
+
+// compress merges the buffered values into the centroids, growing each centroid while
+// its share of the values stays within one unit of the scale function. A value added
+// many times at once is split into centroids of that size, so that the percentiles
+// around it are not interpolated across a single heavy centroid.
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(append(all, t.centroids...), t.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	before := 0.0
+	limit := t.quantileLimit(0)
+	start := func(c centroid) centroid {
+		for {
+			size := math.Max(1, math.Floor(limit*t.count-before))
+			if c.count <= size {
+				return c
+			}
+			merged = append(merged, centroid{mean: c.mean, count: size})
+			before += size
+			limit = t.quantileLimit(before / t.count)
+			c.count -= size
+		}
+	}
+
+	current := start(all[0])
+	for _, c := range all[1:] {
+		if (before+current.count+c.count)/t.count <= limit {
+			current.count += c.count
+			current.mean += (c.mean - current.mean) * c.count / current.count
+			continue
+		}
+		merged = append(merged, current)
+		before += current.count
+		limit = t.quantileLimit(before / t.count)
+		current = start(c)
+	}
+
+	t.centroids = append(merged, current)
+	t.buffer = t.buffer[:0]
+}
+
+// quantileLimit returns the quantile up to which a centroid starting at quantile q may
+// grow, using the scale function k(q) = δ/2π·asin(2q-1)
+func (t *TDigest) quantileLimit(q float64) float64 {
+	k := t.compression/(2*math.Pi)*math.Asin(2*q-1) + 1
+	if k >= t.compression/4 {
+		return 1
+	}
+	return (math.Sin(2*math.Pi*k/t.compression) + 1) / 2
+}
+
+// tdigestJSON is how a TDigest is stored, with the centroids as [mean, count] pairs
+type tdigestJSON struct {
+	Compression float64      `json:"compression"`
+	Count       float64      `json:"count"`
+	Sum         float64      `json:"sum"`
+	SumSquares  float64      `json:"sum_squares"`
+	Min         float64      `json:"min"`
+	Max         float64      `json:"max"`
+	Centroids   [][2]float64 `json:"centroids"`
+}
+
+// MarshalJSON writes the digest with its buffered values merged into the centroids
+func (t *TDigest) MarshalJSON() ([]byte, error) {
+	t.compress()
+	stored := tdigestJSON{
+		Compression: t.compression,
+		Count:       t.count,
+		Sum:         t.sum,
+		SumSquares:  t.sumSquares,
+		Centroids:   make([][2]float64, 0, len(t.centroids)),
+	}
+	if t.count > 0 {
+		stored.Min, stored.Max = t.min, t.max
+	}
+	for _, c := range t.centroids {
+		stored.Centroids = append(stored.Centroids, [2]float64{c.mean, c.count})
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON reads a digest written by MarshalJSON
+func (t *TDigest) UnmarshalJSON(data []byte) error {
+	var stored tdigestJSON
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	*t = *NewTDigest(stored.Compression)
+	t.count, t.sum, t.sumSquares = stored.Count, stored.Sum, stored.SumSquares
+	if t.count > 0 {
+		t.min, t.max = stored.Min, stored.Max
+	}
+	for _, c := range stored.Centroids {
+		t.centroids = append(t.centroids, centroid{mean: c[0], count: c[1]})
+	}
+	return nil
+}
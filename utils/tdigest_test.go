@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTDigest_Quantile(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	exact := NewDistribution(true)
+	digest := NewTDigest(DefaultTDigestCompression)
+	for i := 0; i < 100_000; i++ {
+		x := random.ExpFloat64() * 1000
+		exact.Add(x)
+		digest.Add(x)
+	}
+
+	assert.Equal(t, 100_000, digest.Count())
+	assert.Equal(t, exact.Min(), digest.Min())
+	assert.Equal(t, exact.Max(), digest.Max())
+	assert.InDelta(t, exact.StdDev(), digest.StdDev(), 1e-6)
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99} {
+		assert.InEpsilon(t, exact.Quantile(q), digest.Quantile(q), 0.005, "q=%v", q)
+	}
+	// the centroids near the tails are smaller, but a tail that is also dense is coarser
+	assert.InEpsilon(t, exact.Quantile(0.01), digest.Quantile(0.01), 0.03)
+}
+
+func TestTDigest_AddN(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	exact := NewDistribution(true)
+	digest := NewTDigest(DefaultTDigestCompression)
+	for i := 0; i < 1000; i++ {
+		x, n := float64(1000+random.Intn(200)*50), random.Intn(100)
+		exact.AddN(x, n)
+		digest.AddN(x, n)
+	}
+
+	assert.Equal(t, exact.Count(), digest.Count())
+	assert.Equal(t, exact.Min(), digest.Min())
+	assert.Equal(t, exact.Max(), digest.Max())
+	assert.InDelta(t, exact.Mean(), digest.Mean(), 1e-6)
+	assert.InDelta(t, exact.StdDev(), digest.StdDev(), 1e-6)
+	for _, q := range []float64{0.5, 0.9, 0.95} {
+		assert.InEpsilon(t, exact.Quantile(q), digest.Quantile(q), 0.01, "q=%v", q)
+	}
+
+	deadlines := NewTDigest(DefaultTDigestCompression)
+	deadlines.AddN(3, 2000)
+	deadlines.AddN(5, 5000)
+	deadlines.AddN(8, 3000)
+	assert.Equal(t, 5.0, deadlines.Quantile(0.5))
+	assert.Equal(t, 8.0, deadlines.Quantile(0.9))
+}
+
+func TestTDigest_Merge(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	whole := NewTDigest(DefaultTDigestCompression)
+	merged := NewTDigest(DefaultTDigestCompression)
+	for part := 0; part < 50; part++ {
+		digest := NewTDigest(DefaultTDigestCompression)
+		for i := 0; i < 1000; i++ {
+			x := random.NormFloat64()*100 + float64(part)
+			digest.Add(x)
+			whole.Add(x)
+		}
+		merged.Merge(digest)
+	}
+
+	assert.Equal(t, whole.Count(), merged.Count())
+	assert.Equal(t, whole.Min(), merged.Min())
+	assert.Equal(t, whole.Max(), merged.Max())
+	for _, q := range []float64{0.05, 0.5, 0.95} {
+		assert.InDelta(t, whole.Quantile(q), merged.Quantile(q), 2, "q=%v", q)
+	}
+}
+
+func TestTDigest_FewValues(t *testing.T) {
+	digest := NewTDigest(DefaultTDigestCompression)
+	for _, x := range []float64{4, 1, 3, 2} {
+		digest.Add(x)
+	}
+
+	assert.Equal(t, 1.0, digest.Quantile(0))
+	assert.Equal(t, 2.5, digest.Quantile(0.5))
+	assert.Equal(t, 4.0, digest.Quantile(1))
+	assert.Equal(t, math.Sqrt(1.25), digest.StdDev())
+}
+
+func TestTDigest_JSON(t *testing.T) {
+	digest := NewTDigest(DefaultTDigestCompression)
+	for i := 0; i < 10_000; i++ {
+		digest.Add(float64(i % 97))
+	}
+
+	data, err := json.Marshal(digest)
+	require.NoError(t, err)
+	var decoded TDigest
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, digest.Count(), decoded.Count())
+	assert.Equal(t, digest.Min(), decoded.Min())
+	assert.Equal(t, digest.Max(), decoded.Max())
+	assert.Equal(t, digest.StdDev(), decoded.StdDev())
+	assert.Equal(t, digest.Quantile(0.9), decoded.Quantile(0.9))
+	assert.LessOrEqual(t, len(decoded.centroids), 2*DefaultTDigestCompression)
+}
+
+func TestTDigest_Empty(t *testing.T) {
+	digest := NewTDigest(DefaultTDigestCompression)
+	digest.Merge(nil)
+
+	data, err := json.Marshal(digest)
+	require.NoError(t, err)
+	var decoded TDigest
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Zero(t, decoded.Count())
+	assert.Zero(t, decoded.Quantile(0.5))
+	assert.Zero(t, decoded.StdDev())
+}
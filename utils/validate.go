@@ -1,120 +1,199 @@
 package utils
 
 import (
-	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/belmadge/freteRapido/domain"
 )
 
-func ValidateQuoteInput(input domain.QuoteRequest) error {
-	if input.Shipper.RegisteredNumber == "" || input.Shipper.Token == "" || input.Shipper.PlatformCode == "" {
-		return errors.New("shipper information is incomplete")
-	}
+// ValidateQuoteInput checks every field of the request and its volumes against limits,
+// returning a *domain.ValidationError with all the rules it breaks
+func ValidateQuoteInput(input domain.QuoteRequest, limits domain.QuoteLimits) error {
+	errs := &domain.ValidationError{}
 
-	if input.Recipient.Country == "" || input.Recipient.Zipcode == 0 {
-		return errors.New("recipient information is incomplete")
-	}
+	validateRegisteredNumber(errs, "/shipper/registered_number", input.Shipper.RegisteredNumber)
+	validateRequired(errs, "/shipper/token", input.Shipper.Token)
+	validateRequired(errs, "/shipper/platform_code", input.Shipper.PlatformCode)
+
+	validateRecipient(errs, input.Recipient)
+	validateOptions(errs, input)
 
 	if len(input.Dispatchers) == 0 {
-		return errors.New("at least one dispatcher is required")
+		errs.Add("/dispatchers", domain.RuleMinItems, "must have at least one dispatcher")
 	}
 
-	for _, dispatcher := range input.Dispatchers {
-		if dispatcher.RegisteredNumber == "" || dispatcher.Zipcode == 0 {
-			return errors.New("dispatcher information is incomplete")
+	var totalWeight float64
+	var totalValue domain.Money
+	for i, dispatcher := range input.Dispatchers {
+		path := fmt.Sprintf("/dispatchers/%d", i)
+
+		validateRegisteredNumber(errs, path+"/registered_number", dispatcher.RegisteredNumber)
+		validateCEP(errs, path+"/zipcode", dispatcher.Zipcode)
+		if dispatcher.TotalPrice < 0 {
+			errs.Add(path+"/total_price", domain.RulePositive, "must be greater than zero")
 		}
 
 		if len(dispatcher.Volumes) == 0 {
-			return errors.New("at least one volume is required for each dispatcher")
+			errs.Add(path+"/volumes", domain.RuleMinItems, "must have at least one volume")
 		}
 
-		for _, volume := range dispatcher.Volumes {
-			if volume.Category == "" || volume.Amount <= 0 || volume.UnitaryWeight <= 0 || volume.UnitaryPrice <= 0 {
-				return errors.New("volume information is incomplete or invalid")
-			}
+		for j, volume := range dispatcher.Volumes {
+			validateVolume(errs, fmt.Sprintf("%s/volumes/%d", path, j), volume, limits)
+			totalWeight += float64(volume.Amount) * volume.UnitaryWeight
+			totalValue += volume.UnitaryPrice.Mul(volume.Amount)
 		}
 	}
 
-	return nil
+	if limits.MaxShipmentWeight > 0 && totalWeight > limits.MaxShipmentWeight {
+		errs.Add("/dispatchers", domain.RuleMax, fmt.Sprintf("must weigh at most %s kg in total", formatFloat(limits.MaxShipmentWeight)))
+	}
+	if limits.MaxShipmentValue > 0 && totalValue > limits.MaxShipmentValue {
+		errs.Add("/dispatchers", domain.RuleMax, fmt.Sprintf("must be worth at most %s in total", limits.MaxShipmentValue))
+	}
+
+	return errs.Err()
 }
 
-func ValidateCarriersFromAPIResponse(apiResponse map[string]interface{}) ([]domain.Carrier, error) {
-	dispatchersData, ok := apiResponse["dispatchers"]
-	if !ok {
-		return nil, errors.New("invalid dispatchers data from API response")
+func validateRecipient(errs *domain.ValidationError, recipient domain.Recipient) {
+	switch recipient.Type {
+	case domain.RecipientTypeIndividual:
+		if recipient.RegisteredNumber != "" && !recipient.RegisteredNumber.IsCPF() {
+			errs.Add("/recipient/registered_number", domain.RuleRegisteredNumber, "must be a valid CPF for an individual recipient")
+		}
+	case domain.RecipientTypeCompany:
+		if recipient.RegisteredNumber != "" && !recipient.RegisteredNumber.IsCNPJ() {
+			errs.Add("/recipient/registered_number", domain.RuleRegisteredNumber, "must be a valid CNPJ for a company recipient")
+		}
+	default:
+		errs.Add("/recipient/type", domain.RuleOneOf, "must be 0 (individual) or 1 (company)")
 	}
 
-	dispatchersList, ok := dispatchersData.([]interface{})
-	if !ok {
-		return nil, errors.New("invalid dispatchers list format")
+	if recipient.StateInscription != "" && !validStateInscription(recipient.StateInscription) {
+		errs.Add("/recipient/state_inscription", domain.RuleStateInscription, fmt.Sprintf("must be ISENTO or have at most %d digits", domain.MaxStateInscriptionLength))
 	}
 
-	var carriers []domain.Carrier
-	for _, d := range dispatchersList {
-		dispatcherMap, ok := d.(map[string]interface{})
-		if !ok {
-			return nil, errors.New("invalid dispatcher format in API response")
-		}
+	validateRequired(errs, "/recipient/country", recipient.Country)
+	validateCEP(errs, "/recipient/zipcode", recipient.Zipcode)
+}
 
-		offers, ok := dispatcherMap["offers"].([]interface{})
-		if !ok {
-			return nil, errors.New("missing offers in dispatcher")
+func validateOptions(errs *domain.ValidationError, input domain.QuoteRequest) {
+	for i, simulationType := range input.SimulationType {
+		if simulationType != domain.SimulationTypeFractional && simulationType != domain.SimulationTypeFullLoad {
+			errs.Add(fmt.Sprintf("/simulation_type/%d", i), domain.RuleOneOf, "must be 0 (fractional) or 1 (full load)")
 		}
+	}
 
-		for _, o := range offers {
-			carrier, err := parseCarrier(o)
-			if err != nil {
-				return nil, err
-			}
-			carriers = append(carriers, carrier)
-		}
+	validateMaxLength(errs, "/channel", input.Channel, domain.MaxChannelLength)
+	validateMaxLength(errs, "/identification", input.Identification, domain.MaxIdentificationLength)
+
+	switch input.Filter {
+	case 0, domain.QuoteFilterCheapest, domain.QuoteFilterFastest:
+	default:
+		errs.Add("/filter", domain.RuleOneOf, "must be 1 (cheapest) or 2 (fastest)")
 	}
 
-	return carriers, nil
+	if input.Limit < 0 {
+		errs.Add("/limit", domain.RulePositive, "must be greater than zero")
+	}
 }
 
-func parseCarrier(offer interface{}) (domain.Carrier, error) {
-	offeringMap, ok := offer.(map[string]interface{})
-	if !ok {
-		return domain.Carrier{}, errors.New("invalid offering format in dispatcher")
+func validateVolume(errs *domain.ValidationError, path string, volume domain.Volume, limits domain.QuoteLimits) {
+	switch {
+	case volume.Category == "":
+		errs.Add(path+"/category", domain.RuleRequired, "is required")
+	case !domain.ValidVolumeCategory(volume.Category):
+		errs.Add(path+"/category", domain.RuleCategory, "must be a Frete Rápido volume category code")
 	}
 
-	carrierData, ok := offeringMap["carrier"].(map[string]interface{})
-	if !ok {
-		return domain.Carrier{}, errors.New("missing carrier in offering")
+	if volume.Amount <= 0 {
+		errs.Add(path+"/amount", domain.RulePositive, "must be greater than zero")
+	}
+	if volume.AmountVolumes < 0 {
+		errs.Add(path+"/amount_volumes", domain.RulePositive, "must be greater than zero")
 	}
 
-	carrierName, nameOk := carrierData["name"].(string)
-	price, priceOk := offeringMap["final_price"].(float64)
-	service, serviceOk := offeringMap["service"].(string)
-	deliveryTime, deliveryTimeOk := offeringMap["delivery_time"].(map[string]interface{})
+	validateDimension(errs, path+"/height", volume.Height, limits.MaxDimension)
+	validateDimension(errs, path+"/width", volume.Width, limits.MaxDimension)
+	validateDimension(errs, path+"/length", volume.Length, limits.MaxDimension)
 
-	deadline, err := parseDeliveryTime(deliveryTime)
-	if err != nil {
-		return domain.Carrier{}, err
+	switch {
+	case volume.UnitaryWeight <= 0:
+		errs.Add(path+"/unitary_weight", domain.RulePositive, "must be greater than zero")
+	case limits.MaxVolumeWeight > 0 && volume.UnitaryWeight > limits.MaxVolumeWeight:
+		errs.Add(path+"/unitary_weight", domain.RuleMax, fmt.Sprintf("must be at most %s kg", formatFloat(limits.MaxVolumeWeight)))
 	}
 
-	if !nameOk || !priceOk || !serviceOk || !deliveryTimeOk {
-		return domain.Carrier{}, errors.New("missing or invalid dispatcher fields in API response")
+	switch {
+	case volume.UnitaryPrice <= 0:
+		errs.Add(path+"/unitary_price", domain.RulePositive, "must be greater than zero")
+	case limits.MaxVolumeValue > 0 && volume.UnitaryPrice > limits.MaxVolumeValue:
+		errs.Add(path+"/unitary_price", domain.RuleMax, fmt.Sprintf("must be at most %s", limits.MaxVolumeValue))
 	}
+}
 
-	return domain.Carrier{
-		Name:     carrierName,
-		Price:    price,
-		Service:  service,
-		Deadline: deadline,
-	}, nil
+// validateDimension checks a dimension in meters. Values past the limit are usually
+// given in centimeters by mistake.
+func validateDimension(errs *domain.ValidationError, path string, value, max float64) {
+	switch {
+	case value <= 0:
+		errs.Add(path, domain.RulePositive, "must be greater than zero")
+	case max > 0 && value > max:
+		errs.Add(path, domain.RuleMax, fmt.Sprintf("must be at most %s m (dimensions are in meters)", formatFloat(max)))
+	}
+}
+
+func validateMaxLength(errs *domain.ValidationError, path, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		errs.Add(path, domain.RuleMaxLength, fmt.Sprintf("must have at most %d characters", max))
+	}
+}
+
+func validateRequired(errs *domain.ValidationError, path, value string) {
+	if value == "" {
+		errs.Add(path, domain.RuleRequired, "is required")
+	}
+}
+
+func validateRegisteredNumber(errs *domain.ValidationError, path string, number domain.RegisteredNumber) {
+	switch {
+	case number == "":
+		errs.Add(path, domain.RuleRequired, "is required")
+	case !number.Valid():
+		errs.Add(path, domain.RuleRegisteredNumber, "must be a valid CNPJ or CPF")
+	}
 }
 
-func parseDeliveryTime(deliveryTime map[string]interface{}) (int, error) {
-	if days, ok := deliveryTime["days"].(float64); ok {
-		return int(days), nil
+func validateCEP(errs *domain.ValidationError, path string, cep domain.CEP) {
+	switch {
+	case cep == "":
+		errs.Add(path, domain.RuleRequired, "is required")
+	case !cep.Valid():
+		errs.Add(path, domain.RuleCEP, "must be a CEP with 8 digits")
 	}
-	if minutes, ok := deliveryTime["minutes"].(float64); ok {
-		return int(minutes / 1440), nil
+}
+
+// validStateInscription reports whether value is ISENTO, for recipients exempt from the
+// state tax, or a state inscription number, with or without its mask
+func validStateInscription(value string) bool {
+	if strings.EqualFold(value, "ISENTO") {
+		return true
 	}
-	if hours, ok := deliveryTime["hours"].(float64); ok {
-		return int(hours / 24), nil
+
+	digits := 0
+	for _, c := range value {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c != '.' && c != '/' && c != '-' && c != ' ':
+			return false
+		}
 	}
-	return 0, errors.New("missing days, hours, or minutes in delivery_time")
+	return digits > 0 && digits <= domain.MaxStateInscriptionLength
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
 }
@@ -1,33 +1,34 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/belmadge/freteRapido/domain"
 	"github.com/stretchr/testify/assert"
 )
 
-func TestValidateQuoteInput_Success(t *testing.T) {
-	input := domain.QuoteRequest{
+func validQuoteInput() domain.QuoteRequest {
+	return domain.QuoteRequest{
 		Shipper: domain.Shipper{
-			RegisteredNumber: "123456789",
+			RegisteredNumber: "25438296000158",
 			Token:            "token",
 			PlatformCode:     "platform",
 		},
 		Recipient: domain.Recipient{
 			Country: "BRA",
-			Zipcode: 12345678,
+			Zipcode: "12345678",
 		},
 		Dispatchers: []domain.Dispatcher{
 			{
-				RegisteredNumber: "123456789",
-				Zipcode:          12345678,
+				RegisteredNumber: "25438296000158",
+				Zipcode:          "12345678",
 				Volumes: []domain.Volume{
 					{
 						Category:      "7",
 						Amount:        1,
 						UnitaryWeight: 5,
-						UnitaryPrice:  349,
+						UnitaryPrice:  34900,
 						Height:        0.2,
 						Width:         0.2,
 						Length:        0.2,
@@ -36,351 +37,271 @@ func TestValidateQuoteInput_Success(t *testing.T) {
 			},
 		},
 	}
+}
 
-	err := ValidateQuoteInput(input)
+func TestValidateQuoteInput_Success(t *testing.T) {
+	err := ValidateQuoteInput(validQuoteInput(), domain.DefaultQuoteLimits)
 	assert.NoError(t, err)
 }
 
 func TestValidateQuoteInput_Error(t *testing.T) {
 	tests := []struct {
-		name    string
-		input   domain.QuoteRequest
-		wantErr string
+		name     string
+		modify   func(input *domain.QuoteRequest)
+		expected []domain.FieldViolation
 	}{
 		{
 			name: "missing shipper information",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
-				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
-				},
+			modify: func(input *domain.QuoteRequest) {
+				input.Shipper = domain.Shipper{}
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/shipper/registered_number", Rule: domain.RuleRequired, Message: "is required"},
+				{Field: "/shipper/token", Rule: domain.RuleRequired, Message: "is required"},
+				{Field: "/shipper/platform_code", Rule: domain.RuleRequired, Message: "is required"},
+			},
+		},
+		{
+			name: "invalid shipper registered number",
+			modify: func(input *domain.QuoteRequest) {
+				input.Shipper.RegisteredNumber = "25438296000159"
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/shipper/registered_number", Rule: domain.RuleRegisteredNumber, Message: "must be a valid CNPJ or CPF"},
 			},
-			wantErr: "shipper information is incomplete",
 		},
 		{
 			name: "missing recipient information",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
-				},
-				Recipient: domain.Recipient{},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
-				},
+			modify: func(input *domain.QuoteRequest) {
+				input.Recipient = domain.Recipient{}
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/recipient/country", Rule: domain.RuleRequired, Message: "is required"},
+				{Field: "/recipient/zipcode", Rule: domain.RuleRequired, Message: "is required"},
+			},
+		},
+		{
+			name: "invalid recipient zipcode",
+			modify: func(input *domain.QuoteRequest) {
+				input.Recipient.Zipcode = "1310100"
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/recipient/zipcode", Rule: domain.RuleCEP, Message: "must be a CEP with 8 digits"},
 			},
-			wantErr: "recipient information is incomplete",
 		},
 		{
 			name: "missing dispatchers",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
-				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
-				},
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers = nil
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers", Rule: domain.RuleMinItems, Message: "must have at least one dispatcher"},
 			},
-			wantErr: "at least one dispatcher is required",
 		},
 		{
-			name: "missing dispatcher information",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
-				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
-				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						Volumes: []domain.Volume{
-							{
-								Category:      "7",
-								Amount:        1,
-								UnitaryWeight: 5,
-								UnitaryPrice:  349,
-								Height:        0.2,
-								Width:         0.2,
-								Length:        0.2,
-							},
-						},
-					},
-				},
+			name: "invalid dispatcher information",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].RegisteredNumber = "123456789"
+				input.Dispatchers[0].Zipcode = "0131010A"
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/registered_number", Rule: domain.RuleRegisteredNumber, Message: "must be a valid CNPJ or CPF"},
+				{Field: "/dispatchers/0/zipcode", Rule: domain.RuleCEP, Message: "must be a CEP with 8 digits"},
 			},
-			wantErr: "dispatcher information is incomplete",
 		},
 		{
-			name: "missing volume information",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
-				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
-				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-					},
-				},
+			name: "missing volumes",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].Volumes = nil
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes", Rule: domain.RuleMinItems, Message: "must have at least one volume"},
 			},
-			wantErr: "at least one volume is required for each dispatcher",
 		},
 		{
-			name: "invalid volume information",
-			input: domain.QuoteRequest{
-				Shipper: domain.Shipper{
-					RegisteredNumber: "123456789",
-					Token:            "token",
-					PlatformCode:     "platform",
-				},
-				Recipient: domain.Recipient{
-					Country: "BRA",
-					Zipcode: 12345678,
-				},
-				Dispatchers: []domain.Dispatcher{
-					{
-						RegisteredNumber: "123456789",
-						Zipcode:          12345678,
-						Volumes: []domain.Volume{
-							{
-								Category: "",
-								Amount:   0,
-							},
-						},
-					},
-				},
+			name: "invalid volumes of several dispatchers",
+			modify: func(input *domain.QuoteRequest) {
+				second := domain.Dispatcher{
+					RegisteredNumber: "25438296000158",
+					Zipcode:          "12345678",
+					Volumes:          []domain.Volume{input.Dispatchers[0].Volumes[0], {Amount: 1, UnitaryWeight: -1, UnitaryPrice: 100, Height: 1, Width: 1, Length: 1}},
+				}
+				input.Dispatchers[0].Volumes[0].Category = ""
+				input.Dispatchers = append(input.Dispatchers, second)
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes/0/category", Rule: domain.RuleRequired, Message: "is required"},
+				{Field: "/dispatchers/1/volumes/1/category", Rule: domain.RuleRequired, Message: "is required"},
+				{Field: "/dispatchers/1/volumes/1/unitary_weight", Rule: domain.RulePositive, Message: "must be greater than zero"},
 			},
-			wantErr: "volume information is incomplete or invalid",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := ValidateQuoteInput(tt.input)
-			assert.EqualError(t, err, tt.wantErr)
-		})
-	}
-}
-
-func TestValidateCarriersFromAPIResponse_Success(t *testing.T) {
-	tests := []struct {
-		name        string
-		apiResponse map[string]interface{}
-		expected    []domain.Carrier
-	}{
 		{
-			name: "valid response",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{
-						"offers": []interface{}{
-							map[string]interface{}{
-								"carrier": map[string]interface{}{
-									"name": "Carrier1",
-								},
-								"final_price": 10.0,
-								"service":     "Service1",
-								"delivery_time": map[string]interface{}{
-									"days": 2.0,
-								},
-							},
-						},
-					},
-				},
+			name: "zero volume values",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].Volumes[0] = domain.Volume{Category: "7"}
 			},
-			expected: []domain.Carrier{
-				{Name: "Carrier1", Price: 10.0, Service: "Service1", Deadline: 2},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes/0/amount", Rule: domain.RulePositive, Message: "must be greater than zero"},
+				{Field: "/dispatchers/0/volumes/0/height", Rule: domain.RulePositive, Message: "must be greater than zero"},
+				{Field: "/dispatchers/0/volumes/0/width", Rule: domain.RulePositive, Message: "must be greater than zero"},
+				{Field: "/dispatchers/0/volumes/0/length", Rule: domain.RulePositive, Message: "must be greater than zero"},
+				{Field: "/dispatchers/0/volumes/0/unitary_weight", Rule: domain.RulePositive, Message: "must be greater than zero"},
+				{Field: "/dispatchers/0/volumes/0/unitary_price", Rule: domain.RulePositive, Message: "must be greater than zero"},
 			},
 		},
 		{
-			name: "valid delivery time in minutes",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{
-						"offers": []interface{}{
-							map[string]interface{}{
-								"carrier": map[string]interface{}{
-									"name": "Carrier1",
-								},
-								"final_price": 10.0,
-								"service":     "Service1",
-								"delivery_time": map[string]interface{}{
-									"minutes": 2880.0,
-								},
-							},
-						},
-					},
-				},
+			name: "invalid recipient",
+			modify: func(input *domain.QuoteRequest) {
+				input.Recipient.Type = 2
+				input.Recipient.StateInscription = "123.456.789.012.345"
 			},
-			expected: []domain.Carrier{
-				{Name: "Carrier1", Price: 10.0, Service: "Service1", Deadline: 2},
+			expected: []domain.FieldViolation{
+				{Field: "/recipient/type", Rule: domain.RuleOneOf, Message: "must be 0 (individual) or 1 (company)"},
+				{Field: "/recipient/state_inscription", Rule: domain.RuleStateInscription, Message: "must be ISENTO or have at most 14 digits"},
 			},
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result, err := ValidateCarriersFromAPIResponse(tt.apiResponse)
-			assert.NoError(t, err)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
-func TestValidateCarriersFromAPIResponse_Error(t *testing.T) {
-	tests := []struct {
-		name        string
-		apiResponse map[string]interface{}
-		wantErr     string
-	}{
 		{
-			name:        "missing dispatchers",
-			apiResponse: map[string]interface{}{},
-			wantErr:     "invalid dispatchers data from API response",
+			name: "recipient document of the wrong type",
+			modify: func(input *domain.QuoteRequest) {
+				input.Recipient.RegisteredNumber = "25438296000158"
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/recipient/registered_number", Rule: domain.RuleRegisteredNumber, Message: "must be a valid CPF for an individual recipient"},
+			},
 		},
 		{
-			name: "invalid dispatchers list format",
-			apiResponse: map[string]interface{}{
-				"dispatchers": "invalid",
+			name: "company recipient with a CPF",
+			modify: func(input *domain.QuoteRequest) {
+				input.Recipient.Type = domain.RecipientTypeCompany
+				input.Recipient.RegisteredNumber = "52998224725"
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/recipient/registered_number", Rule: domain.RuleRegisteredNumber, Message: "must be a valid CNPJ for a company recipient"},
 			},
-			wantErr: "invalid dispatchers list format",
 		},
 		{
-			name: "invalid dispatcher format",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					"invalid",
-				},
+			name: "invalid options",
+			modify: func(input *domain.QuoteRequest) {
+				input.SimulationType = []int{0, 3}
+				input.Channel = strings.Repeat("c", 256)
+				input.Identification = strings.Repeat("i", 256)
+				input.Filter = 3
+				input.Limit = -1
+				input.Dispatchers[0].TotalPrice = -100
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/simulation_type/1", Rule: domain.RuleOneOf, Message: "must be 0 (fractional) or 1 (full load)"},
+				{Field: "/channel", Rule: domain.RuleMaxLength, Message: "must have at most 255 characters"},
+				{Field: "/identification", Rule: domain.RuleMaxLength, Message: "must have at most 255 characters"},
+				{Field: "/filter", Rule: domain.RuleOneOf, Message: "must be 1 (cheapest) or 2 (fastest)"},
+				{Field: "/limit", Rule: domain.RulePositive, Message: "must be greater than zero"},
+				{Field: "/dispatchers/0/total_price", Rule: domain.RulePositive, Message: "must be greater than zero"},
 			},
-			wantErr: "invalid dispatcher format in API response",
 		},
 		{
-			name: "missing offers in dispatcher",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{},
-				},
+			name: "negative amount of packages",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].Volumes[0].AmountVolumes = -1
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes/0/amount_volumes", Rule: domain.RulePositive, Message: "must be greater than zero"},
 			},
-			wantErr: "missing offers in dispatcher",
 		},
 		{
-			name: "invalid offering format in dispatcher",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{
-						"offers": []interface{}{
-							"invalid",
-						},
-					},
-				},
+			name: "unknown category",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].Volumes[0].Category = "65"
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes/0/category", Rule: domain.RuleCategory, Message: "must be a Frete Rápido volume category code"},
 			},
-			wantErr: "invalid offering format in dispatcher",
 		},
 		{
-			name: "missing carrier in offering",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{
-						"offers": []interface{}{
-							map[string]interface{}{},
-						},
-					},
-				},
+			name: "dimensions in centimeters",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].Volumes[0].Height = 20
+				input.Dispatchers[0].Volumes[0].Length = 3.5
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes/0/height", Rule: domain.RuleMax, Message: "must be at most 3 m (dimensions are in meters)"},
+				{Field: "/dispatchers/0/volumes/0/length", Rule: domain.RuleMax, Message: "must be at most 3 m (dimensions are in meters)"},
 			},
-			wantErr: "missing carrier in offering",
 		},
 		{
-			name: "missing or invalid dispatcher fields",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{
-						"offers": []interface{}{
-							map[string]interface{}{
-								"carrier":     map[string]interface{}{"name": "Carrier1"},
-								"service":     "Service1",
-								"final_price": 10.0,
-								"delivery_time": map[string]interface{}{
-									"invalid_field": 1,
-								},
-							},
-						},
-					},
-				},
+			name: "volume over the unitary limits",
+			modify: func(input *domain.QuoteRequest) {
+				input.Dispatchers[0].Volumes[0].UnitaryWeight = 1000.5
+				input.Dispatchers[0].Volumes[0].UnitaryPrice = 10_000_001
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers/0/volumes/0/unitary_weight", Rule: domain.RuleMax, Message: "must be at most 1000 kg"},
+				{Field: "/dispatchers/0/volumes/0/unitary_price", Rule: domain.RuleMax, Message: "must be at most 100000.00"},
 			},
-			wantErr: "missing days, hours, or minutes in delivery_time",
 		},
 		{
-			name: "missing days, hours, or minutes in delivery_time",
-			apiResponse: map[string]interface{}{
-				"dispatchers": []interface{}{
-					map[string]interface{}{
-						"offers": []interface{}{
-							map[string]interface{}{
-								"carrier": map[string]interface{}{
-									"name": "Carrier1",
-								},
-								"final_price":   10.0,
-								"service":       "Service1",
-								"delivery_time": map[string]interface{}{},
-							},
-						},
-					},
-				},
+			name: "shipment over the total limits",
+			modify: func(input *domain.QuoteRequest) {
+				volume := input.Dispatchers[0].Volumes[0]
+				volume.Amount = 20
+				volume.UnitaryWeight = 1000
+				volume.UnitaryPrice = 5_000_000
+				input.Dispatchers[0].Volumes[0] = volume
+				input.Dispatchers = append(input.Dispatchers, input.Dispatchers[0])
+			},
+			expected: []domain.FieldViolation{
+				{Field: "/dispatchers", Rule: domain.RuleMax, Message: "must weigh at most 30000 kg in total"},
+				{Field: "/dispatchers", Rule: domain.RuleMax, Message: "must be worth at most 1000000.00 in total"},
 			},
-			wantErr: "missing days, hours, or minutes in delivery_time",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := ValidateCarriersFromAPIResponse(tt.apiResponse)
-			assert.EqualError(t, err, tt.wantErr)
-			assert.Nil(t, result)
+			input := validQuoteInput()
+			tt.modify(&input)
+
+			err := ValidateQuoteInput(input, domain.DefaultQuoteLimits)
+
+			var validationErr *domain.ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, tt.expected, validationErr.Violations)
+			}
 		})
 	}
 }
+
+func TestValidateQuoteInput_Options(t *testing.T) {
+	input := validQuoteInput()
+	input.Recipient.RegisteredNumber = domain.NormalizeRegisteredNumber("529.982.247-25")
+	input.Recipient.StateInscription = "110.042.490.114"
+	input.SimulationType = []int{domain.SimulationTypeFractional, domain.SimulationTypeFullLoad}
+	input.Channel = "marketplace"
+	input.Filter = domain.QuoteFilterFastest
+	input.Limit = 5
+	input.Reverse = true
+	input.Dispatchers[0].TotalPrice = 100000
+
+	err := ValidateQuoteInput(input, domain.DefaultQuoteLimits)
+
+	assert.NoError(t, err)
+}
+
+func TestValidateQuoteInput_ZeroLimitsAreNotEnforced(t *testing.T) {
+	input := validQuoteInput()
+	input.Dispatchers[0].Volumes[0].Height = 20
+	input.Dispatchers[0].Volumes[0].UnitaryWeight = 50_000
+
+	err := ValidateQuoteInput(input, domain.QuoteLimits{})
+
+	assert.NoError(t, err)
+}
+
+func TestValidateQuoteInput_ErrorMessage(t *testing.T) {
+	input := validQuoteInput()
+	input.Shipper.Token = ""
+	input.Dispatchers[0].Volumes[0].Amount = 0
+
+	err := ValidateQuoteInput(input, domain.DefaultQuoteLimits)
+
+	assert.EqualError(t, err, "invalid request: /shipper/token is required; /dispatchers/0/volumes/0/amount must be greater than zero")
+}